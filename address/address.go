@@ -1,6 +1,7 @@
 package address
 
 import (
+	"bytes"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -52,3 +53,23 @@ func (a *Address) UnmarshalJSON(b []byte) error {
 
 	return nil
 }
+
+// Equal compares the workchain and hash parts, ignoring flags
+func Equal(a, b *address.Address) bool {
+	return a.Workchain() == b.Workchain() && bytes.Equal(a.Data(), b.Data())
+}
+
+// Variants returns the user-friendly forms of the address with every
+// combination of the bounceable and testnet only flags
+func Variants(a *address.Address) []string {
+	res := make([]string, 0, 4)
+	for _, bounce := range []bool{true, false} {
+		for _, testnet := range []bool{false, true} {
+			v := address.NewAddress(0, byte(a.Workchain()), a.Data())
+			v.SetBounce(bounce)
+			v.SetTestnetOnly(testnet)
+			res = append(res, v.String())
+		}
+	}
+	return res
+}
@@ -0,0 +1,168 @@
+package claims
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+	myaddr "github.com/ton-community/compressed-nft-api/address"
+	"github.com/ton-community/compressed-nft-api/contract"
+	"github.com/ton-community/compressed-nft-api/provider"
+	"github.com/ton-community/compressed-nft-api/state"
+	"github.com/ton-community/compressed-nft-api/toncenter"
+	"github.com/xssnick/tonutils-go/address"
+)
+
+const (
+	// the largest page Toncenter returns
+	PAGE_SIZE = 100
+	// pages fetched per scan, so that a long history is walked over several
+	// scans instead of being held in memory at once
+	MAX_PAGES = 10
+)
+
+// claimedIndex returns the index of the item deployed by the transaction, or
+// false if the transaction is not a successful claim
+func claimedIndex(tx *toncenter.Transaction, collection *address.Address) (uint64, bool) {
+	if tx.InMsg == nil {
+		return 0, false
+	}
+
+	body, err := tx.InMsg.Body()
+	if err != nil || body == nil {
+		return 0, false
+	}
+
+	index, _, err := contract.ParseClaimBody(body)
+	if err != nil {
+		return 0, false
+	}
+
+	itemAddr, err := contract.ItemAddress(collection, index)
+	if err != nil {
+		return 0, false
+	}
+
+	for _, m := range tx.OutMsgs {
+		dest, err := address.ParseAddr(m.Destination)
+		if err != nil {
+			continue
+		}
+
+		if myaddr.Equal(dest, itemAddr) {
+			return index, true
+		}
+	}
+
+	return 0, false
+}
+
+// Scanner walks the transactions of a collection from the newest one back to
+// the cursor. Claims are recorded page by page, and the cursor is only moved
+// once the walk reaches it, so a walk that is cut short by an error or by
+// MAX_PAGES continues where it stopped on the next scan.
+type Scanner struct {
+	URI           string
+	ClaimProvider provider.ClaimProvider
+
+	collection *address.Address
+	// the newest transaction of the unfinished walk and where it stopped
+	newest uint64
+	lt     uint64
+	hash   string
+}
+
+// Scan records the claims made since the last scan and returns how many it
+// recorded
+func (sc *Scanner) Scan(collection *address.Address) (int, error) {
+	if sc.collection == nil || !myaddr.Equal(sc.collection, collection) {
+		sc.collection = collection
+		sc.newest, sc.lt, sc.hash = 0, 0, ""
+	}
+
+	cursor, err := sc.ClaimProvider.GetCursor()
+	if err != nil {
+		return 0, err
+	}
+
+	claimed := 0
+	for i := 0; i < MAX_PAGES; i++ {
+		page, err := toncenter.GetTransactions(sc.URI, collection, PAGE_SIZE, sc.lt, sc.hash)
+		if err != nil {
+			return claimed, err
+		}
+
+		if sc.lt != 0 && len(page) > 0 {
+			// the page starts with the last transaction of the previous one
+			page = page[1:]
+		}
+
+		done := len(page) == 0
+		for _, tx := range page {
+			txLT, err := tx.LT()
+			if err != nil {
+				return claimed, err
+			}
+
+			if txLT <= cursor {
+				done = true
+				break
+			}
+
+			if index, ok := claimedIndex(tx, collection); ok {
+				err = sc.ClaimProvider.SetClaimed(index, time.Unix(tx.Utime, 0))
+				if err != nil {
+					return claimed, err
+				}
+
+				claimed++
+			}
+
+			if sc.newest == 0 {
+				sc.newest = txLT
+			}
+			sc.lt = txLT
+			sc.hash = tx.TransactionID.Hash
+		}
+
+		if done {
+			if sc.newest != 0 {
+				err = sc.ClaimProvider.SetCursor(sc.newest)
+				if err != nil {
+					return claimed, err
+				}
+			}
+
+			sc.newest, sc.lt, sc.hash = 0, 0, ""
+
+			return claimed, nil
+		}
+	}
+
+	log.Info().Uint64("lt", sc.lt).Msg("claim scan reached the page limit, continuing on the next scan")
+
+	return claimed, nil
+}
+
+func Tracker(uri string, sh *state.StateHolder, cp provider.ClaimProvider, interval time.Duration) {
+	sc := &Scanner{
+		URI:           uri,
+		ClaimProvider: cp,
+	}
+
+	ticker := time.NewTicker(interval)
+	for range ticker.C {
+		s := sh.GetFullState().CurrentState
+		if s.Address == nil {
+			continue
+		}
+
+		claimed, err := sc.Scan(s.Address.Address)
+		if err != nil {
+			log.Err(err).Msg("could not scan claims")
+		}
+
+		if claimed > 0 {
+			log.Info().Int("claimed", claimed).Msg("recorded claims")
+		}
+	}
+}
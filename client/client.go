@@ -0,0 +1,446 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	myaddr "github.com/ton-community/compressed-nft-api/address"
+	"github.com/ton-community/compressed-nft-api/data"
+	"github.com/ton-community/compressed-nft-api/proof"
+	"github.com/ton-community/compressed-nft-api/toncenter"
+	"github.com/ton-community/compressed-nft-api/types"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+const DEFAULT_RETRIES = 3
+const DEFAULT_RETRY_DELAY = 500 * time.Millisecond
+
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("api responded with status %v: %v", e.StatusCode, e.Body)
+}
+
+// Client talks to the v1 API. BaseURL must include the /v1 suffix, the same
+// way it is stored in the collection.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+
+	// Retries is the number of extra attempts for network errors and 5xx and
+	// 429 responses
+	Retries    int
+	RetryDelay time.Duration
+
+	// Toncenter is only needed for the helpers that read the on-chain root
+	Toncenter string
+}
+
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: http.DefaultClient,
+		Retries:    DEFAULT_RETRIES,
+		RetryDelay: DEFAULT_RETRY_DELAY,
+	}
+}
+
+type Item struct {
+	Index    uint64
+	Metadata *data.ItemMetadata
+	DataCell *cell.Cell
+
+	Claimed   bool
+	ClaimedAt *time.Time
+}
+
+func newItem(d *data.ItemData) (*Item, error) {
+	if d == nil {
+		return nil, errors.New("missing item")
+	}
+
+	index, err := strconv.ParseUint(d.Index, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Item{
+		Index:    index,
+		Metadata: d.Metadata,
+		DataCell: d.DataCell,
+
+		Claimed:   d.Claimed,
+		ClaimedAt: d.ClaimedAt,
+	}, nil
+}
+
+type Items struct {
+	Items     []*Item
+	LastIndex uint64
+	Root      types.Node
+}
+
+type ItemWithProof struct {
+	Item      *Item
+	Root      types.Node
+	ProofCell *cell.Cell
+}
+
+// OwnerItems is a page of the indices of the items of an owner; Next is
+// the index to continue from, or nil on the last page
+type OwnerItems struct {
+	Indices   []uint64
+	Next      *uint64
+	LastIndex uint64
+	Root      types.Node
+}
+
+type State struct {
+	Depth     int
+	Capacity  *big.Int
+	LastIndex uint64
+	Root      types.Node
+	Address   *address.Address
+}
+
+type Verification struct {
+	Valid bool
+	Error string
+	Root  types.Node
+	Item  *data.ItemMetadata
+}
+
+func retryable(status int) bool {
+	return status >= 500 || status == http.StatusTooManyRequests
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body any, out any) error {
+	var reqBody []byte
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = b
+	}
+
+	return c.retry(ctx, func() (int, error) {
+		return c.doOnce(ctx, method, path, reqBody, out)
+	})
+}
+
+// retry calls f until it succeeds, fails with a status that is not worth
+// retrying or runs out of attempts; a status of 0 means a network error
+func (c *Client) retry(ctx context.Context, f func() (int, error)) error {
+	var err error
+	for attempt := 0; attempt <= c.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(c.RetryDelay * time.Duration(1<<(attempt-1))):
+			}
+		}
+
+		var status int
+		status, err = f()
+		if err == nil {
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return err
+		}
+
+		if status != 0 && !retryable(status) {
+			return err
+		}
+	}
+
+	return err
+}
+
+func (c *Client) doOnce(ctx context.Context, method, path string, body []byte, out any) (int, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, r)
+	if err != nil {
+		return 0, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, &APIError{
+			StatusCode: resp.StatusCode,
+			Body:       string(b),
+		}
+	}
+
+	return resp.StatusCode, json.Unmarshal(b, out)
+}
+
+func (c *Client) Items(ctx context.Context, from, count uint64) (*Items, error) {
+	var resp struct {
+		Items     []*data.ItemData `json:"items"`
+		LastIndex string           `json:"last_index"`
+		Root      types.Node       `json:"root"`
+	}
+
+	q := url.Values{}
+	q.Set("from", strconv.FormatUint(from, 10))
+	q.Set("count", strconv.FormatUint(count, 10))
+
+	err := c.do(ctx, http.MethodGet, "/items?"+q.Encode(), nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	lastIndex, err := strconv.ParseUint(resp.LastIndex, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*Item, 0, len(resp.Items))
+	for _, d := range resp.Items {
+		item, err := newItem(d)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return &Items{
+		Items:     items,
+		LastIndex: lastIndex,
+		Root:      resp.Root,
+	}, nil
+}
+
+func (c *Client) Item(ctx context.Context, index uint64) (*ItemWithProof, error) {
+	var resp struct {
+		Item      *data.ItemData `json:"item"`
+		Root      types.Node     `json:"root"`
+		ProofCell *cell.Cell     `json:"proof_cell"`
+	}
+
+	err := c.do(ctx, http.MethodGet, "/items/"+strconv.FormatUint(index, 10), nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := newItem(resp.Item)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.ProofCell == nil {
+		return nil, errors.New("missing proof cell")
+	}
+
+	return &ItemWithProof{
+		Item:      item,
+		Root:      resp.Root,
+		ProofCell: resp.ProofCell,
+	}, nil
+}
+
+func (c *Client) OwnerItems(ctx context.Context, owner *address.Address, from, count uint64) (*OwnerItems, error) {
+	var resp struct {
+		Indices   []string   `json:"indices"`
+		Next      string     `json:"next"`
+		LastIndex string     `json:"last_index"`
+		Root      types.Node `json:"root"`
+	}
+
+	q := url.Values{}
+	q.Set("from", strconv.FormatUint(from, 10))
+	q.Set("count", strconv.FormatUint(count, 10))
+
+	err := c.do(ctx, http.MethodGet, "/owners/"+url.PathEscape(owner.String())+"/items?"+q.Encode(), nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	lastIndex, err := strconv.ParseUint(resp.LastIndex, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	indices := make([]uint64, 0, len(resp.Indices))
+	for _, s := range resp.Indices {
+		index, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		indices = append(indices, index)
+	}
+
+	items := &OwnerItems{
+		Indices:   indices,
+		LastIndex: lastIndex,
+		Root:      resp.Root,
+	}
+
+	if resp.Next != "" {
+		next, err := strconv.ParseUint(resp.Next, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		items.Next = &next
+	}
+
+	return items, nil
+}
+
+func (c *Client) State(ctx context.Context) (*State, error) {
+	var resp struct {
+		Depth     int             `json:"depth"`
+		Capacity  string          `json:"capacity"`
+		LastIndex string          `json:"last_index"`
+		Root      types.Node      `json:"root"`
+		Address   *myaddr.Address `json:"address"`
+	}
+
+	err := c.do(ctx, http.MethodGet, "/state", nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	capacity, ok := new(big.Int).SetString(resp.Capacity, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid capacity %q", resp.Capacity)
+	}
+
+	lastIndex, err := strconv.ParseUint(resp.LastIndex, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &State{
+		Depth:     resp.Depth,
+		Capacity:  capacity,
+		LastIndex: lastIndex,
+		Root:      resp.Root,
+	}
+	if resp.Address != nil {
+		s.Address = resp.Address.Address
+	}
+
+	return s, nil
+}
+
+// Verify asks the API to check a proof. A nil root means the committed one.
+func (c *Client) Verify(ctx context.Context, proofCell *cell.Cell, index uint64, root *types.Node) (*Verification, error) {
+	req := struct {
+		ProofCell *cell.Cell  `json:"proof_cell"`
+		Index     string      `json:"index"`
+		Root      *types.Node `json:"root,omitempty"`
+	}{
+		ProofCell: proofCell,
+		Index:     strconv.FormatUint(index, 10),
+		Root:      root,
+	}
+
+	var resp struct {
+		Valid bool               `json:"valid"`
+		Error string             `json:"error"`
+		Root  types.Node         `json:"root"`
+		Item  *data.ItemMetadata `json:"item"`
+	}
+
+	err := c.do(ctx, http.MethodPost, "/verify", req, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Verification{
+		Valid: resp.Valid,
+		Error: resp.Error,
+		Root:  resp.Root,
+		Item:  resp.Item,
+	}, nil
+}
+
+// VerifiedItem fetches an item and checks its proof locally against the given
+// root and depth
+func (c *Client) VerifiedItem(ctx context.Context, index uint64, root types.Node, depth int) (*ItemWithProof, error) {
+	item, err := c.Item(ctx, index)
+	if err != nil {
+		return nil, err
+	}
+
+	itemCell, err := proof.Verify(item.ProofCell, root, index, depth)
+	if err != nil {
+		return nil, err
+	}
+
+	if item.Item.DataCell == nil {
+		return nil, errors.New("missing item data cell")
+	}
+
+	if !bytes.Equal(itemCell.Hash(), item.Item.DataCell.Hash()) {
+		return nil, errors.New("proof is for a different item than the returned one")
+	}
+
+	return item, nil
+}
+
+// OnChainVerifiedItem fetches an item and checks its proof against the root
+// that the collection currently has on chain, which requires Toncenter to be
+// set. A proof that passes this check can be used to claim the item.
+func (c *Client) OnChainVerifiedItem(ctx context.Context, index uint64) (*ItemWithProof, error) {
+	if c.Toncenter == "" {
+		return nil, errors.New("toncenter uri is not set")
+	}
+
+	s, err := c.State(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Address == nil {
+		return nil, errors.New("collection is not deployed yet")
+	}
+
+	var root []byte
+	err = c.retry(ctx, func() (int, error) {
+		var err error
+		root, err = toncenter.GetMerkleRootContext(ctx, c.HTTPClient, c.Toncenter, s.Address)
+		return 0, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return c.VerifiedItem(ctx, index, types.NewNode(root), s.Depth)
+}
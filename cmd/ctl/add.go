@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/spf13/cobra"
+	"github.com/ton-community/compressed-nft-api/config"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+const BOC_PREFIX = "boc:"
+
+// parseContent returns the BOC of the individual content of an item, or nil
+// for the default one. Content prefixed with boc: is a base64 BOC, anything
+// else is stored as a snake string.
+func parseContent(s string) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(s, BOC_PREFIX) {
+		return cell.BeginCell().MustStoreStringSnake(s).EndCell().ToBOC(), nil
+	}
+
+	s = strings.TrimRight(strings.TrimPrefix(s, BOC_PREFIX), "=")
+
+	b, err := base64.RawStdEncoding.DecodeString(s)
+	if err != nil {
+		b, err = base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c, err := cell.FromBOC(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.ToBOC(), nil
+}
+
+// existingExternalIDs returns the errors of rows whose external id is already
+// used by an item in the database
+func existingExternalIDs(ctx context.Context, tx pgx.Tx, rows []*itemRow) ([]*rowError, error) {
+	byID := map[string]*itemRow{}
+	ids := make([]string, 0)
+	for _, row := range rows {
+		if row.externalID != "" {
+			byID[row.externalID] = row
+			ids = append(ids, row.externalID)
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	res, err := tx.Query(ctx, "SELECT id, external_id FROM items WHERE external_id = ANY($1)", ids)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var errs []*rowError
+	var index uint64
+	var id string
+	for res.Next() {
+		err = res.Scan(&index, &id)
+		if err != nil {
+			return nil, err
+		}
+
+		errs = append(errs, &rowError{byID[id].line, fmt.Errorf("external id %q is already used by item %v", id, index)})
+	}
+
+	return errs, res.Err()
+}
+
+func printSummary(w io.Writer, rows []*itemRow, rejected int, start uint64) {
+	var content, externalIDs, attributes int
+	for _, row := range rows {
+		if row.content != nil {
+			content++
+		}
+		if row.externalID != "" {
+			externalIDs++
+		}
+		if len(row.attributes) > 0 {
+			attributes++
+		}
+	}
+
+	indices := "none"
+	if len(rows) > 0 {
+		indices = fmt.Sprintf("%v..%v", start, start+uint64(len(rows))-1)
+	}
+
+	summary := [][2]string{
+		{"items", strconv.Itoa(len(rows))},
+		{"indices", indices},
+		{"with content", strconv.Itoa(content)},
+		{"with external id", strconv.Itoa(externalIDs)},
+		{"with attributes", strconv.Itoa(attributes)},
+		{"rejected lines", strconv.Itoa(rejected)},
+	}
+	for _, l := range summary {
+		fmt.Fprintf(w, "%-20v %v\n", l[0]+":", l[1])
+	}
+}
+
+func insertRows(ctx context.Context, tx pgx.Tx, rows []*itemRow) error {
+	for _, row := range rows {
+		var externalID any
+		if row.externalID != "" {
+			externalID = row.externalID
+		}
+
+		var attributes any
+		if len(row.attributes) > 0 {
+			b, err := json.Marshal(row.attributes)
+			if err != nil {
+				return err
+			}
+			attributes = string(b)
+		}
+
+		_, err := tx.Exec(ctx, "INSERT INTO items (id, owner, content, external_id, attributes) VALUES ($1, $2, $3, $4, $5::jsonb)", *row.index, row.owner.String(), row.content, externalID, attributes)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type rejectedLine struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// writeReport writes the rejected lines as a JSON array to p, or to stdout if
+// p is -
+func writeReport(p string, errs []*rowError) error {
+	rejected := make([]rejectedLine, 0, len(errs))
+	for _, e := range errs {
+		rejected = append(rejected, rejectedLine{
+			Line:  e.line,
+			Error: e.err.Error(),
+		})
+	}
+
+	b, err := json.MarshalIndent(rejected, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	if p == "-" {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+
+	return os.WriteFile(p, b, 0644)
+}
+
+func add(cmd *cobra.Command, args []string) error {
+	config.LoadConfig()
+
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return err
+	}
+
+	strict, err := cmd.Flags().GetBool("strict")
+	if err != nil {
+		return err
+	}
+
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+
+	report, err := cmd.Flags().GetString("report")
+	if err != nil {
+		return err
+	}
+
+	// keep stdout for the report when it is written there
+	var out io.Writer = os.Stdout
+	if report == "-" {
+		out = os.Stderr
+	}
+
+	format, err = importFormat(format, args[0])
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	rows, errs, err := readRows(format, f)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	conn, err := pgx.Connect(ctx, config.Config.Database)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		row := tx.QueryRow(ctx, "SELECT COUNT(*) FROM items")
+		var start uint64
+		err := row.Scan(&start)
+		if err != nil {
+			return err
+		}
+
+		// skipping these would move other rows to different indices, so
+		// they abort the import even without --strict
+		conflicts := assignIndices(rows, start)
+
+		idErrs, err := existingExternalIDs(ctx, tx, rows)
+		if err != nil {
+			return err
+		}
+		conflicts = append(conflicts, idErrs...)
+
+		rejected := append(errs, conflicts...)
+		sort.SliceStable(rejected, func(i, j int) bool {
+			return rejected[i].line < rejected[j].line
+		})
+
+		for _, e := range rejected {
+			fmt.Fprintln(os.Stderr, e)
+		}
+
+		if report != "" {
+			err = writeReport(report, rejected)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(conflicts) > 0 {
+			return fmt.Errorf("%v lines conflict with other items, nothing was added", len(conflicts))
+		}
+
+		if strict && len(errs) > 0 {
+			return fmt.Errorf("%v lines are invalid, nothing was added; pass --strict=false to skip them", len(errs))
+		}
+
+		if dryRun {
+			printSummary(out, rows, len(errs), start)
+			fmt.Fprintln(out, "dry run, nothing was added")
+			return nil
+		}
+
+		err = insertRows(ctx, tx, rows)
+		if err != nil {
+			return err
+		}
+
+		printSummary(out, rows, len(errs), start)
+
+		return nil
+	})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/ton-community/compressed-nft-api/config"
+	"github.com/ton-community/compressed-nft-api/contract"
+	"github.com/xssnick/tonutils-go/address"
+)
+
+func itemAddress(cmd *cobra.Command, args []string) error {
+	collectionString, err := cmd.Flags().GetString("collection")
+	if err != nil {
+		return err
+	}
+
+	var collection *address.Address
+	if collectionString != "" {
+		collection, err = address.ParseAddr(collectionString)
+		if err != nil {
+			return err
+		}
+	} else {
+		config.LoadConfig()
+
+		s, err := newStateProvider().GetState()
+		if err != nil {
+			return err
+		}
+
+		if s.Address == nil {
+			return errors.New("no collection address has been committed yet; pass it with --collection")
+		}
+		collection = s.Address.Address
+	}
+
+	for _, arg := range args {
+		index, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid index '%v': %w", arg, err)
+		}
+
+		addr, err := contract.ItemAddress(collection, index)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%v %v\n", index, addr.String())
+	}
+
+	return nil
+}
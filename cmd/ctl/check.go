@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/ton-community/compressed-nft-api/config"
+	"github.com/ton-community/compressed-nft-api/consistency"
+	"github.com/ton-community/compressed-nft-api/provider/pg"
+)
+
+func check(cmd *cobra.Command, args []string) error {
+	config.LoadConfig()
+
+	pool, err := newPool()
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	s, err := newStateProvider().GetState()
+	if err != nil {
+		return err
+	}
+
+	s.Depth, err = consistency.StateDepth(s, newUpdateRecorder())
+	if err != nil {
+		return err
+	}
+
+	problems, err := consistency.Check(s, pg.NewNodeProvider(pool), pg.NewItemProvider(pool, config.Config.ContentTemplate), config.Config.Toncenter)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("found %v consistency problems", len(problems))
+	}
+
+	fmt.Println("ok")
+
+	return nil
+}
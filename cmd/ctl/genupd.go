@@ -0,0 +1,543 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+	"github.com/spf13/cobra"
+	myaddress "github.com/ton-community/compressed-nft-api/address"
+	"github.com/ton-community/compressed-nft-api/config"
+	"github.com/ton-community/compressed-nft-api/consistency"
+	"github.com/ton-community/compressed-nft-api/contract"
+	"github.com/ton-community/compressed-nft-api/provider"
+	"github.com/ton-community/compressed-nft-api/provider/pg"
+	"github.com/ton-community/compressed-nft-api/transfer"
+	"github.com/ton-community/compressed-nft-api/types"
+	"github.com/ton-community/compressed-nft-api/updates"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"gopkg.in/yaml.v3"
+)
+
+var errAborted = errors.New("aborted")
+
+type collectionConfig struct {
+	Owner            string `json:"owner" yaml:"owner"`
+	CollectionMeta   string `json:"collection_meta" yaml:"collection_meta"`
+	CommonItemMeta   string `json:"common_item_meta" yaml:"common_item_meta"`
+	RoyaltyBase      uint64 `json:"royalty_base" yaml:"royalty_base"`
+	RoyaltyFactor    uint64 `json:"royalty_factor" yaml:"royalty_factor"`
+	RoyaltyRecipient string `json:"royalty_recipient" yaml:"royalty_recipient"`
+	APILink          string `json:"api_link" yaml:"api_link"`
+	Address          string `json:"address" yaml:"address"`
+	DeployAmount     uint64 `json:"deploy_amount" yaml:"deploy_amount"`
+	UpdateAmount     uint64 `json:"update_amount" yaml:"update_amount"`
+	HostedMetadata   bool   `json:"hosted_metadata" yaml:"hosted_metadata"`
+}
+
+func loadCollectionConfig(p string) (*collectionConfig, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var c collectionConfig
+	if strings.EqualFold(filepath.Ext(p), ".json") {
+		err = json.Unmarshal(b, &c)
+	} else {
+		err = yaml.Unmarshal(b, &c)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %v: %w", p, err)
+	}
+
+	return &c, nil
+}
+
+func genupdConfig(cmd *cobra.Command) (*collectionConfig, error) {
+	c := &collectionConfig{
+		DeployAmount: contract.DEPLOY_AMOUNT,
+		UpdateAmount: contract.UPDATE_AMOUNT,
+	}
+
+	p, err := cmd.Flags().GetString("config")
+	if err != nil {
+		return nil, err
+	}
+
+	if p != "" {
+		fc, err := loadCollectionConfig(p)
+		if err != nil {
+			return nil, err
+		}
+
+		if fc.DeployAmount == 0 {
+			fc.DeployAmount = c.DeployAmount
+		}
+		if fc.UpdateAmount == 0 {
+			fc.UpdateAmount = c.UpdateAmount
+		}
+		c = fc
+	}
+
+	stringFlags := map[string]*string{
+		"owner":             &c.Owner,
+		"collection-meta":   &c.CollectionMeta,
+		"item-meta":         &c.CommonItemMeta,
+		"royalty-recipient": &c.RoyaltyRecipient,
+		"api-link":          &c.APILink,
+		"collection":        &c.Address,
+	}
+	for name, v := range stringFlags {
+		if cmd.Flags().Changed(name) {
+			*v, err = cmd.Flags().GetString(name)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	uintFlags := map[string]*uint64{
+		"royalty-base":   &c.RoyaltyBase,
+		"royalty-factor": &c.RoyaltyFactor,
+		"deploy-amount":  &c.DeployAmount,
+		"update-amount":  &c.UpdateAmount,
+	}
+	for name, v := range uintFlags {
+		if cmd.Flags().Changed(name) {
+			*v, err = cmd.Flags().GetUint64(name)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if cmd.Flags().Changed("hosted-metadata") {
+		c.HostedMetadata, err = cmd.Flags().GetBool("hosted-metadata")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// legacyCreateArgs fills the config from the positional arguments that genupd
+// used to require for a 'create' update
+func legacyCreateArgs(c *collectionConfig, args []string) error {
+	royaltyBase, err := strconv.ParseUint(args[3], 10, 64)
+	if err != nil {
+		return err
+	}
+
+	royaltyFactor, err := strconv.ParseUint(args[4], 10, 64)
+	if err != nil {
+		return err
+	}
+
+	c.Owner = args[0]
+	c.CollectionMeta = args[1]
+	c.CommonItemMeta = args[2]
+	c.RoyaltyBase = royaltyBase
+	c.RoyaltyFactor = royaltyFactor
+	c.RoyaltyRecipient = args[5]
+	c.APILink = args[6]
+
+	return nil
+}
+
+// hostedMetadata points the collection and item metadata at the /meta
+// endpoints of the API that api_link belongs to
+func (c *collectionConfig) hostedMetadata() error {
+	if c.CollectionMeta != "" || c.CommonItemMeta != "" {
+		return errors.New("collection meta and common item meta cannot be set together with hosted metadata")
+	}
+
+	if c.APILink == "" {
+		return errors.New("hosted metadata needs the api link")
+	}
+
+	base := strings.TrimSuffix(strings.TrimSuffix(c.APILink, "/"), "/v1")
+	c.CollectionMeta = base + "/meta/collection.json"
+	c.CommonItemMeta = base + "/meta/items/"
+
+	return nil
+}
+
+func (c *collectionConfig) params() (*contract.CollectionParams, error) {
+	if c.HostedMetadata {
+		err := c.hostedMetadata()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.Owner == "" || c.RoyaltyRecipient == "" || c.CollectionMeta == "" || c.APILink == "" {
+		return nil, errors.New("owner, collection meta, royalty recipient and api link must be set with flags or in the config file")
+	}
+
+	owner, err := address.ParseAddr(c.Owner)
+	if err != nil {
+		return nil, fmt.Errorf("invalid owner: %w", err)
+	}
+
+	royaltyRecipient, err := address.ParseAddr(c.RoyaltyRecipient)
+	if err != nil {
+		return nil, fmt.Errorf("invalid royalty recipient: %w", err)
+	}
+
+	p := &contract.CollectionParams{
+		Owner:            owner,
+		CollectionMeta:   c.CollectionMeta,
+		CommonItemMeta:   c.CommonItemMeta,
+		RoyaltyBase:      c.RoyaltyBase,
+		RoyaltyFactor:    c.RoyaltyFactor,
+		RoyaltyRecipient: royaltyRecipient,
+		APILink:          c.APILink,
+	}
+
+	return p, p.Validate()
+}
+
+func confirm(cmd *cobra.Command, summary [][2]string) error {
+	for _, l := range summary {
+		fmt.Printf("%-20v %v\n", l[0]+":", l[1])
+	}
+	fmt.Println()
+
+	yes, err := cmd.Flags().GetBool("yes")
+	if err != nil {
+		return err
+	}
+	if yes {
+		return nil
+	}
+
+	fmt.Print("generate the link? [y/N] ")
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		return errAborted
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer != "y" && answer != "yes" {
+		return errAborted
+	}
+	fmt.Println()
+
+	return nil
+}
+
+func formatAmount(nano uint64) string {
+	return fmt.Sprintf("%v TON", tlb.FromNanoTONU(nano).String())
+}
+
+var allFormats = []string{"deeplink", "tonkeeper", "tonconnect", "boc", "qr"}
+
+func outputFormats(cmd *cobra.Command) (map[string]bool, error) {
+	list, err := cmd.Flags().GetStringSlice("format")
+	if err != nil {
+		return nil, err
+	}
+
+	formats := map[string]bool{}
+	for _, f := range list {
+		if f == "all" {
+			for _, f := range allFormats {
+				formats[f] = true
+			}
+			continue
+		}
+
+		known := false
+		for _, a := range allFormats {
+			known = known || a == f
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown format '%v', expected one of: %v, all", f, strings.Join(allFormats, ", "))
+		}
+		formats[f] = true
+	}
+
+	return formats, nil
+}
+
+func writeBOC(dir string, name string, c *cell.Cell) error {
+	p := filepath.Join(dir, name)
+	err := os.WriteFile(p, c.ToBOC(), 0644)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%v written to %v\n", name, p)
+
+	return nil
+}
+
+func writeMessage(cmd *cobra.Command, formats map[string]bool, kind string, m *transfer.Message) error {
+	if formats["deeplink"] {
+		fmt.Printf("%v link:\n%v\n\n", kind, m.Deeplink())
+	}
+
+	if formats["tonkeeper"] {
+		fmt.Printf("%v tonkeeper link:\n%v\n\n", kind, m.TonkeeperLink())
+	}
+
+	if formats["tonconnect"] {
+		b, err := json.MarshalIndent(transfer.TonConnect(m), "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%v tonconnect request:\n%v\n\n", kind, string(b))
+	}
+
+	if formats["boc"] {
+		dir, err := cmd.Flags().GetString("out")
+		if err != nil {
+			return err
+		}
+
+		if m.StateInit != nil {
+			err = writeBOC(dir, kind+"-state-init.boc", m.StateInit)
+			if err != nil {
+				return err
+			}
+		}
+
+		if m.Body != nil {
+			err = writeBOC(dir, kind+"-body.boc", m.Body)
+			if err != nil {
+				return err
+			}
+		}
+		fmt.Println()
+	}
+
+	if formats["qr"] {
+		qr, err := qrcode.New(m.Deeplink(), qrcode.Low)
+		if err != nil {
+			return fmt.Errorf("%v link does not fit in a qr code: %w", kind, err)
+		}
+		fmt.Printf("%v qr code:\n%v\n", kind, qr.ToSmallString(false))
+	}
+
+	return nil
+}
+
+func genupd(cmd *cobra.Command, args []string) error {
+	b, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	var m struct {
+		Type string `json:"type"`
+	}
+	err = json.Unmarshal(b, &m)
+	if err != nil {
+		return err
+	}
+
+	c, err := genupdConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	formats, err := outputFormats(cmd)
+	if err != nil {
+		return err
+	}
+
+	switch m.Type {
+	case "create":
+		switch len(args) {
+		case 1:
+		case 1 + 7:
+			err = legacyCreateArgs(c, args[1:])
+			if err != nil {
+				return err
+			}
+		default:
+			return errors.New("pass the collection params with flags or --config")
+		}
+
+		var upd updates.Create
+		err = json.Unmarshal(b, &upd)
+		if err != nil {
+			return err
+		}
+
+		root, err := hex.DecodeString(upd.Root)
+		if err != nil || len(root) != types.NODE_LENGTH {
+			return errors.New("invalid root")
+		}
+
+		params, err := c.params()
+		if err != nil {
+			return err
+		}
+
+		coll, err := contract.NewCollection(types.NewNode(root), upd.Depth, params)
+		if err != nil {
+			return err
+		}
+		addr := coll.Address
+
+		err = confirm(cmd, [][2]string{
+			{"collection address", addr.String()},
+			{"owner", params.Owner.String()},
+			{"collection meta", params.CollectionMeta},
+			{"common item meta", params.CommonItemMeta},
+			{"royalty", fmt.Sprintf("%v/%v to %v", params.RoyaltyBase, params.RoyaltyFactor, params.RoyaltyRecipient.String())},
+			{"api link", params.APILink},
+			{"depth", strconv.Itoa(upd.Depth)},
+			{"items", strconv.FormatUint(upd.LastIndex+1, 10)},
+			{"root", upd.Root},
+			{"amount", formatAmount(c.DeployAmount)},
+		})
+		if err != nil {
+			return err
+		}
+
+		save, err := cmd.Flags().GetBool("save")
+		if err != nil {
+			return err
+		}
+
+		if save {
+			err = saveCollection(coll)
+			if err != nil {
+				return fmt.Errorf("could not save the collection: %w", err)
+			}
+		}
+
+		fmt.Printf("collection address: %v\n\n", addr.String())
+
+		err = writeMessage(cmd, formats, "deploy", &transfer.Message{
+			Destination: addr,
+			Amount:      c.DeployAmount,
+			StateInit:   coll.StateInit,
+		})
+		if err != nil {
+			return err
+		}
+	case "update":
+		switch len(args) {
+		case 1:
+		case 1 + 1:
+			c.Address = args[1]
+		default:
+			return errors.New("too many args to create an 'update' body; need: collection")
+		}
+
+		var upd updates.Update
+		err = json.Unmarshal(b, &upd)
+		if err != nil {
+			return err
+		}
+
+		config.LoadConfig()
+
+		s, err := newStateProvider().GetState()
+		if err != nil {
+			return err
+		}
+
+		var collection *address.Address
+		if s.Address != nil {
+			collection = s.Address.Address
+		}
+		if c.Address != "" {
+			collection, err = address.ParseAddr(c.Address)
+			if err != nil {
+				return err
+			}
+		}
+		if collection == nil {
+			return errors.New("collection address is not known yet; pass it with --collection")
+		}
+
+		bodyCell, err := checkUpdate(&upd, s, collection)
+		if err != nil {
+			return fmt.Errorf("refusing to generate an update link: %w", err)
+		}
+
+		err = confirm(cmd, [][2]string{
+			{"collection address", collection.String()},
+			{"version", fmt.Sprintf("%v -> %v", s.Version, s.Version+1)},
+			{"items", fmt.Sprintf("%v -> %v", s.LastIndex+1, upd.NewLastIndex+1)},
+			{"root", upd.Root},
+			{"amount", formatAmount(c.UpdateAmount)},
+		})
+		if err != nil {
+			return err
+		}
+
+		err = writeMessage(cmd, formats, "update", &transfer.Message{
+			Destination: collection,
+			Amount:      c.UpdateAmount,
+			Body:        bodyCell,
+		})
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown update type '%v'", m.Type)
+	}
+
+	return nil
+}
+
+func saveCollection(coll *types.Collection) error {
+	config.LoadConfig()
+
+	coll.ContentTemplate = config.Config.ContentTemplate
+
+	pool, err := newPool()
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	cp := pg.NewCollectionProvider(pool)
+
+	prev, err := cp.GetCollection()
+	if err != nil && err != provider.ErrCollectionNotExist {
+		return err
+	}
+	if err == nil && !myaddress.Equal(prev.Address, coll.Address) {
+		fmt.Printf("replacing the previously saved collection %v\n", prev.Address.String())
+	}
+
+	return cp.SetCollection(coll)
+}
+
+func checkUpdate(upd *updates.Update, s *types.State, collection *address.Address) (*cell.Cell, error) {
+	if s.Version == 0 {
+		return nil, errors.New("no version has been committed yet")
+	}
+
+	if s.Address != nil && !myaddress.Equal(s.Address.Address, collection) {
+		return nil, fmt.Errorf("the committed collection address is %v", s.Address.String())
+	}
+
+	if upd.NewLastIndex <= s.LastIndex {
+		return nil, fmt.Errorf("update ends at index %v, but %v is already committed", upd.NewLastIndex, s.LastIndex)
+	}
+
+	depth, err := consistency.StateDepth(s, newUpdateRecorder())
+	if err != nil {
+		return nil, err
+	}
+
+	return contract.CheckUpdate(upd, depth, s.Root)
+}
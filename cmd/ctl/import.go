@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ton-community/compressed-nft-api/types"
+	"github.com/xssnick/tonutils-go/address"
+)
+
+const (
+	FORMAT_AUTO   = "auto"
+	FORMAT_LINES  = "lines"
+	FORMAT_CSV    = "csv"
+	FORMAT_NDJSON = "ndjson"
+)
+
+// CSV columns starting with ATTR_PREFIX hold the value of the attribute named
+// by the rest of the column
+const ATTR_PREFIX = "attr."
+
+const MAX_LINE_LENGTH = 1 << 20
+
+// itemRow is an item read from an import file; index is nil if it should
+// follow the previous row
+type itemRow struct {
+	line       int
+	owner      *address.Address
+	index      *uint64
+	content    []byte
+	externalID string
+	attributes []types.Attribute
+}
+
+type rowError struct {
+	line int
+	err  error
+}
+
+func (e *rowError) Error() string {
+	return fmt.Sprintf("line %v: %v", e.line, e.err)
+}
+
+func importFormat(format string, p string) (string, error) {
+	switch format {
+	case FORMAT_LINES, FORMAT_CSV, FORMAT_NDJSON:
+		return format, nil
+	case FORMAT_AUTO:
+	default:
+		return "", fmt.Errorf("unknown format %v", format)
+	}
+
+	switch strings.ToLower(filepath.Ext(p)) {
+	case ".csv":
+		return FORMAT_CSV, nil
+	case ".ndjson", ".jsonl":
+		return FORMAT_NDJSON, nil
+	default:
+		return FORMAT_LINES, nil
+	}
+}
+
+func readRows(format string, r io.Reader) ([]*itemRow, []*rowError, error) {
+	switch format {
+	case FORMAT_CSV:
+		return readCSV(r)
+	case FORMAT_NDJSON:
+		return readNDJSON(r)
+	default:
+		return readLines(r)
+	}
+}
+
+// readLines reads one owner per line, optionally followed by a space and the
+// content
+func readLines(r io.Reader) ([]*itemRow, []*rowError, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, MAX_LINE_LENGTH)
+
+	var rows []*itemRow
+	var errs []*rowError
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		txt := strings.TrimSpace(scanner.Text())
+		if txt == "" {
+			continue
+		}
+
+		owner, content, _ := strings.Cut(txt, " ")
+
+		row, err := newItemRow(line, owner, nil, strings.TrimSpace(content), "", nil)
+		if err != nil {
+			errs = append(errs, &rowError{line, err})
+			continue
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, errs, scanner.Err()
+}
+
+func readCSV(r io.Reader) ([]*itemRow, []*rowError, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, nil, errors.New("csv file has no header")
+		}
+		return nil, nil, err
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == "owner", name == "index", name == "content", name == "external_id", name == "attributes":
+		case strings.HasPrefix(name, ATTR_PREFIX) && len(name) > len(ATTR_PREFIX):
+		default:
+			return nil, nil, fmt.Errorf("unknown csv column %q", name)
+		}
+
+		if _, ok := columns[name]; ok {
+			return nil, nil, fmt.Errorf("csv column %q is repeated", name)
+		}
+		columns[name] = i
+	}
+
+	if _, ok := columns["owner"]; !ok {
+		return nil, nil, errors.New("csv header has no owner column")
+	}
+
+	get := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var rows []*itemRow
+	var errs []*rowError
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var pe *csv.ParseError
+			if errors.As(err, &pe) && pe.Err == csv.ErrFieldCount {
+				errs = append(errs, &rowError{pe.Line, pe.Err})
+				continue
+			}
+			return nil, nil, err
+		}
+
+		line, _ := cr.FieldPos(0)
+
+		var index *uint64
+		if s := get(record, "index"); s != "" {
+			i, err := strconv.ParseUint(s, 10, 64)
+			if err != nil {
+				errs = append(errs, &rowError{line, fmt.Errorf("bad index %q", s)})
+				continue
+			}
+			index = &i
+		}
+
+		var attrs []types.Attribute
+		if s := get(record, "attributes"); s != "" {
+			d := json.NewDecoder(strings.NewReader(s))
+			d.UseNumber()
+			err := d.Decode(&attrs)
+			if err != nil {
+				errs = append(errs, &rowError{line, fmt.Errorf("bad attributes: %w", err)})
+				continue
+			}
+		}
+
+		for _, name := range header {
+			name = strings.TrimSpace(name)
+			if !strings.HasPrefix(name, ATTR_PREFIX) {
+				continue
+			}
+
+			if v := get(record, name); v != "" {
+				attrs = append(attrs, types.Attribute{
+					TraitType: strings.TrimPrefix(name, ATTR_PREFIX),
+					Value:     v,
+				})
+			}
+		}
+
+		row, err := newItemRow(line, get(record, "owner"), index, get(record, "content"), get(record, "external_id"), attrs)
+		if err != nil {
+			errs = append(errs, &rowError{line, err})
+			continue
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, errs, nil
+}
+
+type ndjsonRow struct {
+	Owner      string            `json:"owner"`
+	Index      *uint64           `json:"index"`
+	Content    string            `json:"content"`
+	ExternalID string            `json:"external_id"`
+	Attributes []types.Attribute `json:"attributes"`
+}
+
+func readNDJSON(r io.Reader) ([]*itemRow, []*rowError, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, MAX_LINE_LENGTH)
+
+	var rows []*itemRow
+	var errs []*rowError
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		b := bytes.TrimSpace(scanner.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+
+		var nr ndjsonRow
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.DisallowUnknownFields()
+		d.UseNumber()
+		err := d.Decode(&nr)
+		if err != nil {
+			errs = append(errs, &rowError{line, err})
+			continue
+		}
+
+		row, err := newItemRow(line, nr.Owner, nr.Index, nr.Content, nr.ExternalID, nr.Attributes)
+		if err != nil {
+			errs = append(errs, &rowError{line, err})
+			continue
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, errs, scanner.Err()
+}
+
+func newItemRow(line int, owner string, index *uint64, content string, externalID string, attrs []types.Attribute) (*itemRow, error) {
+	if owner == "" {
+		return nil, errors.New("owner is missing")
+	}
+
+	addr, err := address.ParseAddr(owner)
+	if err != nil {
+		return nil, fmt.Errorf("bad owner %q: %w", owner, err)
+	}
+
+	contentBOC, err := parseContent(content)
+	if err != nil {
+		return nil, fmt.Errorf("bad content %q: %w", content, err)
+	}
+
+	for _, a := range attrs {
+		if a.TraitType == "" {
+			return nil, errors.New("attribute has no trait_type")
+		}
+
+		switch a.Value.(type) {
+		case string, json.Number:
+		default:
+			return nil, fmt.Errorf("attribute %q must have a string or number value", a.TraitType)
+		}
+	}
+
+	return &itemRow{
+		line:       line,
+		owner:      addr,
+		index:      index,
+		content:    contentBOC,
+		externalID: externalID,
+		attributes: attrs,
+	}, nil
+}
+
+// assignIndices gives rows without an index the one after the previous row,
+// starting from start, and checks that the rows cover [start, start+len(rows))
+// exactly once, since the tree can only be appended to
+func assignIndices(rows []*itemRow, start uint64) []*rowError {
+	var errs []*rowError
+
+	next := start
+	seen := map[uint64]int{}
+	externalIDs := map[string]int{}
+	for _, row := range rows {
+		if row.index == nil {
+			i := next
+			row.index = &i
+		}
+		next = *row.index + 1
+
+		if *row.index < start || *row.index >= start+uint64(len(rows)) {
+			errs = append(errs, &rowError{row.line, fmt.Errorf("index %v is outside of the range %v..%v the items are appended to", *row.index, start, start+uint64(len(rows))-1)})
+			continue
+		}
+
+		if prev, ok := seen[*row.index]; ok {
+			errs = append(errs, &rowError{row.line, fmt.Errorf("index %v is already used on line %v", *row.index, prev)})
+			continue
+		}
+		seen[*row.index] = row.line
+
+		if row.externalID == "" {
+			continue
+		}
+
+		if prev, ok := externalIDs[row.externalID]; ok {
+			errs = append(errs, &rowError{row.line, fmt.Errorf("external id %q is already used on line %v", row.externalID, prev)})
+			continue
+		}
+		externalIDs[row.externalID] = row.line
+	}
+
+	return errs
+}
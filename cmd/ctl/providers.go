@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"path"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/ton-community/compressed-nft-api/config"
+	"github.com/ton-community/compressed-nft-api/provider/file"
+	"github.com/ton-community/compressed-nft-api/updates"
+)
+
+func newPool() (*pgxpool.Pool, error) {
+	return pgxpool.New(context.Background(), config.Config.Database)
+}
+
+func newStateProvider() *file.StateProvider {
+	return &file.StateProvider{
+		Path: path.Join(config.Config.DataDir, "state.json"),
+	}
+}
+
+func newUpdateRecorder() *updates.FileUpdateRecorder {
+	return &updates.FileUpdateRecorder{
+		Base: path.Join(config.Config.DataDir, "upd"),
+	}
+}
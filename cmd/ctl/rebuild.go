@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/spf13/cobra"
+	"github.com/ton-community/compressed-nft-api/config"
+	"github.com/ton-community/compressed-nft-api/consistency"
+	"github.com/ton-community/compressed-nft-api/merkle"
+	"github.com/ton-community/compressed-nft-api/provider/pg"
+	"github.com/ton-community/compressed-nft-api/updates"
+)
+
+func loadHistory(up updates.Recorder) ([]updates.Record, error) {
+	history := make([]updates.Record, 0)
+	for version := 1; ; version++ {
+		var rec updates.Record
+		err := up.Load(version, &rec)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return history, nil
+			}
+			return nil, err
+		}
+
+		if len(history) > 0 && rec.GetLastIndex() <= history[len(history)-1].GetLastIndex() {
+			return nil, fmt.Errorf("update %v has last index %v, which does not grow the tree", version, rec.GetLastIndex())
+		}
+
+		history = append(history, rec)
+	}
+}
+
+func rebuildTree(cmd *cobra.Command, args []string) error {
+	config.LoadConfig()
+
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return err
+	}
+
+	s, err := newStateProvider().GetState()
+	if err != nil {
+		return err
+	}
+
+	up := newUpdateRecorder()
+
+	// nothing is committed before the first version, so it is built with the
+	// configured depth
+	depth, err := consistency.StateDepth(s, up)
+	if err != nil {
+		return err
+	}
+	if depth == 0 {
+		depth = config.Config.Depth
+	}
+	if depth != config.Config.Depth {
+		fmt.Printf("configured DEPTH is %v, but the collection was created with depth %v; rebuilding with %v\n", config.Config.Depth, depth, depth)
+	}
+
+	history, err := loadHistory(up)
+	if err != nil {
+		return err
+	}
+
+	if len(history) < s.Version {
+		return fmt.Errorf("found update files only up to version %v, but version %v is committed", len(history), s.Version)
+	}
+	if len(history) == 0 {
+		return errors.New("no update files found, nothing to rebuild")
+	}
+
+	pool, err := newPool()
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	ctx := context.Background()
+
+	// the pool has not opened any other connection yet, so every other one
+	// belongs to someone else
+	if !force {
+		row := pool.QueryRow(ctx, "SELECT COUNT(*) FROM pg_stat_activity WHERE datname = current_database() AND pid <> pg_backend_pid()")
+		var others int
+		err = row.Scan(&others)
+		if err != nil {
+			return err
+		}
+
+		if others > 0 {
+			return fmt.Errorf("%v other connections to the database are open, the server may be running; stop it first or pass --force", others)
+		}
+	}
+
+	ip := pg.NewItemProvider(pool, config.Config.ContentTemplate)
+
+	counts := make([]uint64, 0, len(history))
+	for _, rec := range history {
+		counts = append(counts, rec.GetLastIndex()+1)
+	}
+
+	roots, err := merkle.ComputeRoots(ip, depth, counts)
+	if err != nil {
+		return err
+	}
+
+	for i, rec := range history {
+		if roots[i] != rec.Root {
+			return fmt.Errorf("items table does not produce the recorded root of version %v at depth %v, refusing to rebuild", i+1, depth)
+		}
+	}
+
+	fmt.Printf("items match all %v recorded versions, rebuilding nodes\n", len(history))
+
+	err = pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		// a running server keeps reading the old nodes until the rebuild is
+		// committed, and its writes wait for it
+		_, err := tx.Exec(ctx, "LOCK TABLE nodes IN EXCLUSIVE MODE")
+		if err != nil {
+			return err
+		}
+
+		np := pg.NewTxNodeProvider(tx)
+
+		err = np.DeleteAll()
+		if err != nil {
+			return err
+		}
+
+		tree := merkle.NewTree(np, depth, 0, 0)
+		for _, rec := range history {
+			root, err := tree.AppendFrom(ip, rec.GetLastIndex()+1)
+			if err != nil {
+				return err
+			}
+
+			if root != rec.Root {
+				return fmt.Errorf("rebuilt root of version %v does not match the recorded one", tree.Version())
+			}
+
+			fmt.Printf("version %v: ok\n", tree.Version())
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("nodes table was left unchanged: %w", err)
+	}
+
+	fmt.Println("rebuilt nodes committed")
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/spf13/cobra"
+	"github.com/ton-community/compressed-nft-api/config"
+	"github.com/ton-community/compressed-nft-api/consistency"
+	"github.com/ton-community/compressed-nft-api/merkle"
+	"github.com/ton-community/compressed-nft-api/provider"
+	"github.com/ton-community/compressed-nft-api/provider/pg"
+	"github.com/ton-community/compressed-nft-api/toncenter"
+	"github.com/ton-community/compressed-nft-api/types"
+	"github.com/ton-community/compressed-nft-api/updates"
+)
+
+func verify(cmd *cobra.Command, args []string) error {
+	config.LoadConfig()
+
+	version, err := cmd.Flags().GetInt("version")
+	if err != nil {
+		return err
+	}
+
+	onChain, err := cmd.Flags().GetBool("chain")
+	if err != nil {
+		return err
+	}
+
+	s, err := newStateProvider().GetState()
+	if err != nil {
+		return err
+	}
+
+	if version == 0 {
+		version = s.Version
+	}
+	if version == 0 {
+		return errors.New("no version has been committed yet, use --version to pick a pending one")
+	}
+
+	// nothing is committed before the first version, so it is built with the
+	// configured depth
+	depth, err := consistency.StateDepth(s, newUpdateRecorder())
+	if err != nil {
+		return err
+	}
+	if depth == 0 {
+		depth = config.Config.Depth
+	}
+	if depth != config.Config.Depth {
+		fmt.Printf("configured DEPTH is %v, but the collection was created with depth %v; verifying with %v\n", config.Config.Depth, depth, depth)
+	}
+
+	expected := map[string]types.Node{}
+
+	var lastIndex uint64
+	if version == s.Version {
+		lastIndex = s.LastIndex
+		expected["state.json"] = s.Root
+	}
+
+	var rec updates.Record
+	err = newUpdateRecorder().Load(version, &rec)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) || version != s.Version {
+			return err
+		}
+	} else {
+		if version == s.Version && rec.GetLastIndex() != lastIndex {
+			return fmt.Errorf("state.json has last index %v, but update %v has %v", lastIndex, version, rec.GetLastIndex())
+		}
+		lastIndex = rec.GetLastIndex()
+		expected[fmt.Sprintf("update %v", version)] = rec.Root
+	}
+
+	pool, err := newPool()
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	err = consistency.CheckContentTemplate(pg.NewCollectionProvider(pool), config.Config.ContentTemplate)
+	if err != nil {
+		return err
+	}
+
+	ip := pg.NewItemProvider(pool, config.Config.ContentTemplate)
+	np := pg.NewNodeProvider(pool)
+
+	root, err := merkle.ComputeRoot(ip, depth, lastIndex+1)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("version %v, last index %v\ncomputed root: %v\n", version, lastIndex, hex.EncodeToString(root.Hash[:]))
+
+	node, err := np.GetNode(1, version)
+	if err != nil {
+		if err != provider.ErrNodeNotExist {
+			return err
+		}
+		fmt.Println("nodes table: missing")
+	} else {
+		expected["nodes table"] = node
+	}
+
+	if onChain {
+		if s.Address == nil {
+			return errors.New("collection address is not known yet")
+		}
+
+		chainRoot, err := toncenter.GetMerkleRoot(config.Config.Toncenter, s.Address.Address)
+		if err != nil {
+			return err
+		}
+
+		expected["chain"] = types.NewNode(chainRoot)
+	}
+
+	mismatches := 0
+	for _, source := range []string{"state.json", fmt.Sprintf("update %v", version), "nodes table", "chain"} {
+		n, ok := expected[source]
+		if !ok {
+			continue
+		}
+
+		if bytes.Equal(n.Hash[:], root.Hash[:]) {
+			fmt.Printf("%v: ok\n", source)
+		} else {
+			fmt.Printf("%v: MISMATCH, has %v\n", source, hex.EncodeToString(n.Hash[:]))
+			mismatches++
+		}
+	}
+
+	if mismatches > 0 {
+		return fmt.Errorf("computed root does not match %v sources", mismatches)
+	}
+
+	return nil
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ton-community/compressed-nft-api/contract"
+	"github.com/ton-community/compressed-nft-api/data"
+	"github.com/ton-community/compressed-nft-api/toncenter"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+var ErrNotDeployed = errors.New("contract is not deployed")
+
+type collection struct {
+	data    *contract.CollectionData
+	address *address.Address
+}
+
+type item struct {
+	collection *address.Address
+	index      uint64
+	metadata   *data.ItemMetadata
+}
+
+// chain keeps the state of the emulated contracts and applies messages to
+// them the same way the collection and item contracts would, see reference.go
+type chain struct {
+	mu           sync.Mutex
+	collections  map[string]*collection
+	items        map[string]*item
+	transactions map[string][]*toncenter.Transaction
+	lt           uint64
+}
+
+func newChain() *chain {
+	return &chain{
+		collections:  map[string]*collection{},
+		items:        map[string]*item{},
+		transactions: map[string][]*toncenter.Transaction{},
+	}
+}
+
+func key(addr *address.Address) string {
+	return fmt.Sprintf("%v:%v", addr.Workchain(), hex.EncodeToString(addr.Data()))
+}
+
+type message struct {
+	sender      *address.Address
+	destination *address.Address
+	amount      uint64
+	stateInit   *cell.Cell
+	body        *cell.Cell
+}
+
+func (ch *chain) apply(msg *message) (string, error) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	deployed := ""
+	if msg.stateInit != nil {
+		if key(contract.AddressOf(msg.stateInit)) != key(msg.destination) {
+			return "", errors.New("state init does not match the destination address")
+		}
+
+		if _, ok := ch.collections[key(msg.destination)]; !ok {
+			d, err := contract.ParseCollectionStateInit(msg.stateInit)
+			if err != nil {
+				return "", err
+			}
+
+			ch.collections[key(msg.destination)] = &collection{
+				data:    d,
+				address: msg.destination,
+			}
+			deployed = "deployed collection; "
+		}
+	}
+
+	c, ok := ch.collections[key(msg.destination)]
+	if !ok {
+		return "", ErrNotDeployed
+	}
+
+	if msg.body == nil {
+		ch.record(msg, nil)
+		return deployed + "no body", nil
+	}
+
+	op, err := msg.body.BeginParse().LoadUInt(32)
+	if err != nil {
+		return "", err
+	}
+
+	var res string
+	var out *address.Address
+	switch op {
+	case contract.OP_UPDATE:
+		res, err = ch.update(c, msg)
+	case contract.OP_CLAIM:
+		res, out, err = ch.claim(c, msg)
+	default:
+		err = fmt.Errorf("unknown op %x", op)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	ch.record(msg, out)
+
+	return deployed + res, nil
+}
+
+// record adds a transaction of the destination that received msg and sent a
+// message to out, if it is not nil
+func (ch *chain) record(msg *message, out *address.Address) {
+	ch.lt++
+
+	tx := &toncenter.Transaction{
+		Utime: time.Now().Unix(),
+		TransactionID: toncenter.TransactionID{
+			LT:   strconv.FormatUint(ch.lt, 10),
+			Hash: base64.StdEncoding.EncodeToString(binary.BigEndian.AppendUint64(nil, ch.lt)),
+		},
+		InMsg:   &toncenter.Message{},
+		OutMsgs: []*toncenter.Message{},
+	}
+
+	if msg.sender != nil {
+		tx.InMsg.Source = msg.sender.String()
+	}
+	tx.InMsg.Destination = msg.destination.String()
+	tx.InMsg.Value = strconv.FormatUint(msg.amount, 10)
+	tx.InMsg.MsgData.Type = "msg.dataRaw"
+	if msg.body != nil {
+		tx.InMsg.MsgData.Body = base64.StdEncoding.EncodeToString(msg.body.ToBOC())
+	}
+
+	if out != nil {
+		m := &toncenter.Message{
+			Source:      msg.destination.String(),
+			Destination: out.String(),
+			Value:       "50000000",
+		}
+		m.MsgData.Type = "msg.dataRaw"
+		tx.OutMsgs = append(tx.OutMsgs, m)
+	}
+
+	k := key(msg.destination)
+	ch.transactions[k] = append(ch.transactions[k], tx)
+}
+
+// getTransactions returns up to limit transactions of the account, newest
+// first, starting from the one with the given lt or from the latest one if lt
+// is 0
+func (ch *chain) getTransactions(addr *address.Address, limit int, lt uint64) []*toncenter.Transaction {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	txs := ch.transactions[key(addr)]
+
+	res := []*toncenter.Transaction{}
+	for i := len(txs) - 1; i >= 0 && len(res) < limit; i-- {
+		txLT, _ := txs[i].LT()
+		if lt != 0 && txLT > lt {
+			continue
+		}
+		res = append(res, txs[i])
+	}
+
+	return res
+}
+
+func (ch *chain) update(c *collection, msg *message) (string, error) {
+	if msg.sender == nil || key(msg.sender) != key(c.data.Owner) {
+		return "", errors.New("update must be sent by the collection owner")
+	}
+
+	updateCell, err := parseUpdate(msg.body)
+	if err != nil {
+		return "", err
+	}
+
+	oldRoot, newRoot, err := applyUpdate(updateCell, c.data.Depth)
+	if err != nil {
+		return "", err
+	}
+
+	if oldRoot != c.data.Root.Hash {
+		return "", fmt.Errorf("update proves old root %v, but collection has %v", hex.EncodeToString(oldRoot[:]), hex.EncodeToString(c.data.Root.Hash[:]))
+	}
+
+	c.data.Root.Hash = newRoot
+
+	return fmt.Sprintf("updated root to %v", hex.EncodeToString(newRoot[:])), nil
+}
+
+func (ch *chain) claim(c *collection, msg *message) (string, *address.Address, error) {
+	if msg.amount < contract.CLAIM_AMOUNT {
+		return "", nil, fmt.Errorf("claim needs at least %v nanotons, got %v", contract.CLAIM_AMOUNT, msg.amount)
+	}
+
+	bigIndex, proofCell, err := parseClaim(msg.body)
+	if err != nil {
+		return "", nil, err
+	}
+
+	itemCell, err := checkClaim(bigIndex, proofCell, c.data.Root.Hash, c.data.Depth)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// the item contract stores its index in 64 bits
+	if !bigIndex.IsUint64() {
+		return "", nil, fmt.Errorf("index %v is too large", bigIndex)
+	}
+	index := bigIndex.Uint64()
+
+	metadata, err := data.ParseItemMetadata(itemCell)
+	if err != nil {
+		return "", nil, err
+	}
+
+	itemAddr, err := contract.ItemAddress(c.address, index)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if _, ok := ch.items[key(itemAddr)]; ok {
+		return "", nil, fmt.Errorf("item %v is already claimed", index)
+	}
+
+	ch.items[key(itemAddr)] = &item{
+		collection: c.address,
+		index:      index,
+		metadata:   metadata,
+	}
+
+	return fmt.Sprintf("deployed item %v at %v for %v", index, itemAddr.String(), metadata.Owner.Address.String()), itemAddr, nil
+}
+
+func (ch *chain) merkleRoot(addr *address.Address) ([]byte, error) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	c, ok := ch.collections[key(addr)]
+	if !ok {
+		return nil, ErrNotDeployed
+	}
+
+	root := c.data.Root
+
+	return root.Hash[:], nil
+}
+
+func (ch *chain) isActive(addr *address.Address) bool {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	_, ok := ch.collections[key(addr)]
+	if ok {
+		return true
+	}
+
+	_, ok = ch.items[key(addr)]
+
+	return ok
+}
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	myaddr "github.com/ton-community/compressed-nft-api/address"
+	"github.com/ton-community/compressed-nft-api/client"
+	"github.com/ton-community/compressed-nft-api/config"
+	"github.com/ton-community/compressed-nft-api/contract"
+	"github.com/ton-community/compressed-nft-api/data"
+	myhttp "github.com/ton-community/compressed-nft-api/http"
+	"github.com/ton-community/compressed-nft-api/provider"
+	"github.com/ton-community/compressed-nft-api/provider/file"
+	"github.com/ton-community/compressed-nft-api/state"
+	"github.com/ton-community/compressed-nft-api/types"
+	"github.com/ton-community/compressed-nft-api/updates"
+	"github.com/xssnick/tonutils-go/address"
+)
+
+const (
+	E2E_DEPTH    = 4
+	E2E_OWNER    = "EQBvW8Z5huBkMJYdnfAEM5JqTNkuWX3diqYENkWsIL0XggGG"
+	E2E_TEMPLATE = "{index}.json"
+	E2E_USERNAME = "admin"
+	E2E_PASSWORD = "secret"
+)
+
+type memNodes map[uint64]map[int]types.Node
+
+func (m memNodes) GetNode(index uint64, version int) (types.Node, error) {
+	best := -1
+	for v := range m[index] {
+		if v <= version && v > best {
+			best = v
+		}
+	}
+	if best < 0 {
+		return types.Node{}, provider.ErrNodeNotExist
+	}
+
+	return m[index][best], nil
+}
+
+func (m memNodes) SetNode(index uint64, version int, node types.Node) error {
+	if m[index] == nil {
+		m[index] = map[int]types.Node{}
+	}
+	m[index][version] = node
+
+	return nil
+}
+
+// memItems holds count items owned by addresses derived from their index
+type memItems struct {
+	count uint64
+}
+
+func testItem(index uint64) *data.ItemMetadata {
+	b := make([]byte, 32)
+	b[0] = byte(index + 1)
+
+	return &data.ItemMetadata{
+		Owner:             &myaddr.Address{Address: address.NewAddress(0, 0, b)},
+		IndividualContent: data.ContentCell(E2E_TEMPLATE, index),
+	}
+}
+
+func (m *memItems) GetItem(index uint64) (*data.ItemMetadata, error) {
+	if index >= m.count {
+		return nil, fmt.Errorf("no item %v", index)
+	}
+
+	return testItem(index), nil
+}
+
+func (m *memItems) GetItems(from, count uint64) ([]*data.ItemMetadata, error) {
+	items := make([]*data.ItemMetadata, 0, count)
+	for i := from; i < from+count && i < m.count; i++ {
+		items = append(items, testItem(i))
+	}
+
+	return items, nil
+}
+
+func (m *memItems) Count() (uint64, error) {
+	return m.count, nil
+}
+
+func (m *memItems) GetOwnerItems(owner *address.Address, from, to, count uint64) ([]uint64, error) {
+	return nil, nil
+}
+
+type memCollection struct{}
+
+func (memCollection) GetCollection() (*types.Collection, error) {
+	return nil, provider.ErrCollectionNotExist
+}
+
+func (memCollection) SetCollection(c *types.Collection) error {
+	return nil
+}
+
+type memClaims struct{}
+
+func (memClaims) GetClaims(from, count uint64) (map[uint64]time.Time, error) {
+	return map[uint64]time.Time{}, nil
+}
+
+func (memClaims) SetClaimed(index uint64, at time.Time) error {
+	return nil
+}
+
+func (memClaims) GetStats() (*types.ClaimStats, error) {
+	return &types.ClaimStats{}, nil
+}
+
+func (memClaims) GetCursor() (uint64, error) {
+	return 0, nil
+}
+
+func (memClaims) SetCursor(lt uint64) error {
+	return nil
+}
+
+type memAttributes struct{}
+
+func (memAttributes) GetAttributes(index uint64) ([]types.Attribute, error) {
+	return nil, nil
+}
+
+type e2e struct {
+	t       *testing.T
+	dir     string
+	ctl     string
+	chain   *chain
+	fake    *httptest.Server
+	api     *httptest.Server
+	items   *memItems
+	holder  *state.StateHolder
+	updates string
+}
+
+func (e *e2e) admin(path string) {
+	e.t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, e.api.URL+path, nil)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	req.SetBasicAuth(E2E_USERNAME, E2E_PASSWORD)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		e.t.Fatalf("%v: status %v", path, resp.StatusCode)
+	}
+}
+
+// genupd runs ctl genupd and returns the lines it printed
+func (e *e2e) genupd(args ...string) []string {
+	e.t.Helper()
+
+	cmd := exec.Command(e.ctl, append([]string{"genupd", "-y"}, args...)...)
+	cmd.Dir = e.dir
+	cmd.Env = append(os.Environ(),
+		"POSTGRES_URI=postgres://unused",
+		"PORT=8080",
+		"ADMIN_USERNAME="+E2E_USERNAME,
+		"ADMIN_PASSWORD="+E2E_PASSWORD,
+		fmt.Sprintf("DEPTH=%v", E2E_DEPTH),
+		"DATA_DIR="+e.dir,
+		"TONCENTER_URI="+e.fake.URL+"/",
+		"CONTENT_TEMPLATE="+E2E_TEMPLATE,
+	)
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		e.t.Fatalf("genupd: %v\n%s", err, out)
+	}
+
+	return strings.Split(string(out), "\n")
+}
+
+func find(lines []string, prefix string) string {
+	for _, l := range lines {
+		if strings.HasPrefix(l, prefix) {
+			return l
+		}
+	}
+
+	return ""
+}
+
+// send submits a transfer link to the fake chain and returns its error, if any
+func (e *e2e) send(link string, sender string) error {
+	b, err := json.Marshal(&sendTransferRequest{Link: link, Sender: sender})
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.Post(e.fake.URL+"/sendTransfer", "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var r toncenterResponse
+	err = json.NewDecoder(resp.Body).Decode(&r)
+	if err != nil {
+		return err
+	}
+
+	if !r.Ok {
+		return fmt.Errorf("rejected: %v", r.Error)
+	}
+
+	return nil
+}
+
+func (e *e2e) waitVersion(version int) {
+	e.t.Helper()
+
+	deadline := time.Now().Add(15 * time.Second)
+	for e.holder.GetFullState().CurrentState.Version != version {
+		if time.Now().After(deadline) {
+			e.t.Fatalf("version %v was not committed", version)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func (e *e2e) claimLink(index uint64) string {
+	e.t.Helper()
+
+	resp, err := http.Get(fmt.Sprintf("%v/v1/items/%v/claim", e.api.URL, index))
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		e.t.Fatalf("claim of item %v: status %v", index, resp.StatusCode)
+	}
+
+	var r myhttp.ClaimResponse
+	err = json.NewDecoder(resp.Body).Decode(&r)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+
+	return r.Link
+}
+
+func newE2E(t *testing.T) *e2e {
+	dir := t.TempDir()
+
+	ctl := filepath.Join(dir, "ctl")
+	out, err := exec.Command("go", "build", "-o", ctl, "../ctl").CombinedOutput()
+	if err != nil {
+		t.Fatalf("could not build ctl: %v\n%s", err, out)
+	}
+
+	ch := newChain()
+	fe := echo.New()
+	(&handler{chain: ch}).register(fe)
+	fake := httptest.NewServer(fe)
+	t.Cleanup(fake.Close)
+
+	config.Config.Toncenter = fake.URL + "/"
+	config.Config.AdminUsername = E2E_USERNAME
+	config.Config.AdminPassword = E2E_PASSWORD
+
+	items := &memItems{}
+	holder := state.NewStateHolder(&types.State{})
+	newStates := make(chan *types.State, 16)
+	addrs := make(chan *address.Address, 16)
+
+	go updates.Watcher(newStates, addrs, holder, &file.StateProvider{Path: filepath.Join(dir, "state.json")})
+
+	matcher, err := data.NewContentMatcher(E2E_TEMPLATE)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := &myhttp.Handler{
+		NodeProvider:       memNodes{},
+		ItemProvider:       items,
+		CollectionProvider: memCollection{},
+		ClaimProvider:      memClaims{},
+		AttributeProvider:  memAttributes{},
+		StateHolder:        holder,
+		Depth:              E2E_DEPTH,
+		ContentTemplate:    E2E_TEMPLATE,
+		ContentMatcher:     matcher,
+		NewStates:          newStates,
+		Addresses:          addrs,
+		UpdateRecorder:     &updates.FileUpdateRecorder{Base: filepath.Join(dir, "upd")},
+	}
+	ae := echo.New()
+	h.RegisterHandlers(ae)
+	api := httptest.NewServer(ae)
+	t.Cleanup(api.Close)
+
+	return &e2e{
+		t:       t,
+		dir:     dir,
+		ctl:     ctl,
+		chain:   ch,
+		fake:    fake,
+		api:     api,
+		items:   items,
+		holder:  holder,
+		updates: filepath.Join(dir, "upd"),
+	}
+}
+
+// TestEndToEnd deploys a collection with genupd, updates it and claims items
+// through the API, with the fake chain checking every message
+func TestEndToEnd(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds ctl")
+	}
+
+	e := newE2E(t)
+
+	e.items.count = 5
+	e.admin("/admin/rediscover")
+
+	out := e.genupd(
+		"--owner", E2E_OWNER,
+		"--collection-meta", "https://example.com/collection.json",
+		"--item-meta", "https://example.com/items/",
+		"--royalty-base", "1",
+		"--royalty-factor", "100",
+		"--royalty-recipient", E2E_OWNER,
+		"--api-link", e.api.URL+"/v1",
+		filepath.Join(e.updates, "1.json"),
+	)
+
+	collection := strings.TrimSpace(strings.TrimPrefix(find(out, "collection address:"), "collection address:"))
+	if collection == "" {
+		t.Fatalf("genupd printed no collection address:\n%v", strings.Join(out, "\n"))
+	}
+
+	err := e.send(find(out, "ton://"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e.admin("/admin/setaddr/" + collection)
+	e.waitVersion(1)
+
+	e.items.count = 12
+	e.admin("/admin/rediscover")
+
+	link := find(e.genupd(filepath.Join(e.updates, "2.json")), "ton://")
+
+	stranger := address.NewAddress(0, 0, bytes.Repeat([]byte{7}, 32)).String()
+	err = e.send(link, stranger)
+	if err == nil {
+		t.Fatal("fake chain accepted an update from a stranger")
+	}
+
+	err = e.send(link, E2E_OWNER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e.waitVersion(2)
+
+	err = e.send(link, E2E_OWNER)
+	if err == nil {
+		t.Fatal("fake chain accepted the same update twice")
+	}
+
+	cl := client.New(e.api.URL + "/v1")
+	cl.Toncenter = e.fake.URL + "/"
+
+	collectionAddr := address.MustParseAddr(collection)
+	for _, index := range []uint64{0, 4, 5, 11} {
+		_, err := cl.OnChainVerifiedItem(context.Background(), index)
+		if err != nil {
+			t.Fatalf("item %v: %v", index, err)
+		}
+
+		err = e.send(e.claimLink(index), "")
+		if err != nil {
+			t.Fatalf("claim of item %v: %v", index, err)
+		}
+
+		itemAddr, err := contract.ItemAddress(collectionAddr, index)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !e.chain.isActive(itemAddr) {
+			t.Fatalf("item %v was not deployed", index)
+		}
+	}
+
+	err = e.send(e.claimLink(0), "")
+	if err == nil {
+		t.Fatal("fake chain deployed an item twice")
+	}
+
+	// a proof of item 6 does not prove item 7
+	it, err := cl.Item(context.Background(), 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forged := contract.ClaimBody(7, it.ProofCell, 0)
+	_, err = e.chain.apply(&message{
+		destination: collectionAddr,
+		amount:      contract.CLAIM_AMOUNT,
+		body:        forged,
+	})
+	if err == nil {
+		t.Fatal("fake chain accepted a proof for a different index")
+	}
+}
@@ -0,0 +1,249 @@
+// Fakechain emulates the parts of the Toncenter API that the server uses and
+// the collection and item contracts, so that the whole deploy, update and claim
+// cycle can be run without a blockchain. Point TONCENTER_URI at it and submit
+// the links produced by ctl to /sendTransfer.
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/rs/zerolog/log"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+type handler struct {
+	chain *chain
+}
+
+type toncenterResponse struct {
+	Ok     bool   `json:"ok"`
+	Result any    `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Code   int    `json:"code,omitempty"`
+}
+
+func fail(c echo.Context, status int, err error) error {
+	return c.JSON(status, &toncenterResponse{
+		Ok:    false,
+		Error: err.Error(),
+		Code:  status,
+	})
+}
+
+type runGetMethodRequest struct {
+	Address string `json:"address"`
+	Method  string `json:"method"`
+	Stack   []any  `json:"stack"`
+}
+
+type runGetMethodResult struct {
+	GasUsed  int     `json:"gas_used"`
+	Stack    [][]any `json:"stack"`
+	ExitCode int     `json:"exit_code"`
+}
+
+func (h *handler) runGetMethod(c echo.Context) error {
+	req := new(runGetMethodRequest)
+	if err := c.Bind(req); err != nil {
+		return fail(c, http.StatusBadRequest, err)
+	}
+
+	addr, err := address.ParseAddr(req.Address)
+	if err != nil {
+		return fail(c, http.StatusBadRequest, err)
+	}
+
+	res := &runGetMethodResult{
+		Stack: [][]any{},
+	}
+
+	root, err := h.chain.merkleRoot(addr)
+	if err != nil {
+		res.ExitCode = -13
+	} else if req.Method != "get_merkle_root" {
+		res.ExitCode = 11
+	} else {
+		res.Stack = append(res.Stack, []any{"num", "0x" + new(big.Int).SetBytes(root).Text(16)})
+	}
+
+	return c.JSON(http.StatusOK, &toncenterResponse{
+		Ok:     true,
+		Result: res,
+	})
+}
+
+func (h *handler) getAddressState(c echo.Context) error {
+	addr, err := address.ParseAddr(c.QueryParam("address"))
+	if err != nil {
+		return fail(c, http.StatusBadRequest, err)
+	}
+
+	state := "uninitialized"
+	if h.chain.isActive(addr) {
+		state = "active"
+	}
+
+	return c.JSON(http.StatusOK, &toncenterResponse{
+		Ok:     true,
+		Result: state,
+	})
+}
+
+func (h *handler) getTransactions(c echo.Context) error {
+	addr, err := address.ParseAddr(c.QueryParam("address"))
+	if err != nil {
+		return fail(c, http.StatusBadRequest, err)
+	}
+
+	limit := 10
+	if c.QueryParam("limit") != "" {
+		limit, err = strconv.Atoi(c.QueryParam("limit"))
+		if err != nil {
+			return fail(c, http.StatusBadRequest, err)
+		}
+	}
+
+	lt := uint64(0)
+	if c.QueryParam("lt") != "" {
+		lt, err = strconv.ParseUint(c.QueryParam("lt"), 10, 64)
+		if err != nil {
+			return fail(c, http.StatusBadRequest, err)
+		}
+	}
+
+	return c.JSON(http.StatusOK, &toncenterResponse{
+		Ok:     true,
+		Result: h.chain.getTransactions(addr, limit, lt),
+	})
+}
+
+func decodeBOC(s string) (*cell.Cell, error) {
+	s = strings.TrimRight(s, "=")
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		b, err = base64.RawStdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return cell.FromBOC(b)
+}
+
+func parseTransferLink(link string) (*message, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme != "ton" || u.Host != "transfer" {
+		return nil, errors.New("not a ton://transfer link")
+	}
+
+	dest, err := address.ParseAddr(strings.TrimPrefix(u.Path, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	q := u.Query()
+
+	amount, err := strconv.ParseUint(q.Get("amount"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("bad amount: %w", err)
+	}
+
+	msg := &message{
+		destination: dest,
+		amount:      amount,
+	}
+
+	if q.Has("init") {
+		msg.stateInit, err = decodeBOC(q.Get("init"))
+		if err != nil {
+			return nil, fmt.Errorf("bad init: %w", err)
+		}
+	}
+
+	if q.Has("bin") {
+		msg.body, err = decodeBOC(q.Get("bin"))
+		if err != nil {
+			return nil, fmt.Errorf("bad bin: %w", err)
+		}
+	}
+
+	return msg, nil
+}
+
+type sendTransferRequest struct {
+	Link   string `json:"link"`
+	Sender string `json:"sender"`
+}
+
+func (h *handler) sendTransfer(c echo.Context) error {
+	req := new(sendTransferRequest)
+	if err := c.Bind(req); err != nil {
+		return fail(c, http.StatusBadRequest, err)
+	}
+
+	msg, err := parseTransferLink(req.Link)
+	if err != nil {
+		return fail(c, http.StatusBadRequest, err)
+	}
+
+	if req.Sender != "" {
+		msg.sender, err = address.ParseAddr(req.Sender)
+		if err != nil {
+			return fail(c, http.StatusBadRequest, err)
+		}
+	}
+
+	res, err := h.chain.apply(msg)
+	if err != nil {
+		log.Err(err).Str("destination", msg.destination.String()).Msg("message rejected")
+		return fail(c, http.StatusBadRequest, err)
+	}
+
+	log.Info().Str("destination", msg.destination.String()).Msg(res)
+
+	return c.JSON(http.StatusOK, &toncenterResponse{
+		Ok:     true,
+		Result: res,
+	})
+}
+
+func (h *handler) register(e *echo.Echo) {
+	e.POST("/runGetMethod", h.runGetMethod)
+	e.GET("/getAddressState", h.getAddressState)
+	e.GET("/getTransactions", h.getTransactions)
+	e.POST("/sendTransfer", h.sendTransfer)
+}
+
+func main() {
+	port := flag.Int("port", 8090, "port to listen on")
+	flag.Parse()
+
+	h := &handler{
+		chain: newChain(),
+	}
+
+	e := echo.New()
+
+	e.Use(middleware.Logger())
+	e.Use(middleware.Recover())
+
+	h.register(e)
+
+	e.Logger.Fatal(e.Start(fmt.Sprintf(":%v", *port)))
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ton-community/compressed-nft-api/contract"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+// The checks in this file follow the collection contract on their own instead
+// of calling the contract and proof packages, so that a bug there makes the
+// fake chain reject the messages that genupd and the API build.
+
+// nodeHash is the hash of a cell that holds the hashes of the two children and
+// no refs, which is how the contract hashes a node
+func nodeHash(left, right [32]byte) [32]byte {
+	// the two cell descriptor bytes: no refs, 512 bits of data
+	b := make([]byte, 0, 2+64)
+	b = append(b, 0, 128)
+	b = append(b, left[:]...)
+	b = append(b, right[:]...)
+
+	return sha256.Sum256(b)
+}
+
+func emptyHash(height int) [32]byte {
+	var h [32]byte
+	for i := 0; i < height; i++ {
+		h = nodeHash(h, h)
+	}
+
+	return h
+}
+
+func loadHash(s *cell.Slice) ([32]byte, error) {
+	var h [32]byte
+
+	b, err := s.LoadSlice(256)
+	if err != nil {
+		return h, err
+	}
+	copy(h[:], b)
+
+	return h, nil
+}
+
+func loadRefCell(s *cell.Slice) (*cell.Cell, error) {
+	ref, err := s.LoadRef()
+	if err != nil {
+		return nil, err
+	}
+
+	return ref.ToCell()
+}
+
+func parseUpdate(body *cell.Cell) (*cell.Cell, error) {
+	s := body.BeginParse()
+
+	op, err := s.LoadUInt(32)
+	if err != nil {
+		return nil, err
+	}
+	if op != contract.OP_UPDATE {
+		return nil, fmt.Errorf("op %x is not an update", op)
+	}
+
+	_, err = s.LoadUInt(64)
+	if err != nil {
+		return nil, err
+	}
+
+	return loadRefCell(s)
+}
+
+// subtree is a node of the pruned tree in an update that is not split further
+type subtree struct {
+	height   int
+	old, new [32]byte
+}
+
+// applyUpdate returns the root the update proves for the current tree and the
+// root it leaves. The pruned tree is flattened into its subtrees from left to
+// right, and neighbours of the same height are folded into their parent until
+// only the root is left.
+func applyUpdate(updateCell *cell.Cell, depth int) ([32]byte, [32]byte, error) {
+	type pending struct {
+		c      *cell.Cell
+		height int
+	}
+
+	var folded []subtree
+	stack := []pending{{updateCell, depth}}
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		s := p.c.BeginParse()
+
+		isLeaf, err := s.LoadBoolBit()
+		if err != nil {
+			return [32]byte{}, [32]byte{}, err
+		}
+
+		if !isLeaf {
+			if p.height == 0 {
+				return [32]byte{}, [32]byte{}, errors.New("update splits a leaf")
+			}
+			if s.RefsNum() != 2 {
+				return [32]byte{}, [32]byte{}, fmt.Errorf("fork has %v refs", s.RefsNum())
+			}
+
+			left, err := loadRefCell(s)
+			if err != nil {
+				return [32]byte{}, [32]byte{}, err
+			}
+			right, err := loadRefCell(s)
+			if err != nil {
+				return [32]byte{}, [32]byte{}, err
+			}
+
+			stack = append(stack, pending{right, p.height - 1}, pending{left, p.height - 1})
+			continue
+		}
+
+		provided, err := s.LoadBoolBit()
+		if err != nil {
+			return [32]byte{}, [32]byte{}, err
+		}
+
+		h, err := loadHash(s)
+		if err != nil {
+			return [32]byte{}, [32]byte{}, err
+		}
+
+		t := subtree{height: p.height, old: h, new: h}
+		if !provided {
+			t.old = emptyHash(p.height)
+		}
+
+		folded = append(folded, t)
+		for len(folded) > 1 && folded[len(folded)-1].height == folded[len(folded)-2].height {
+			l, r := folded[len(folded)-2], folded[len(folded)-1]
+			folded = append(folded[:len(folded)-2], subtree{
+				height: l.height + 1,
+				old:    nodeHash(l.old, r.old),
+				new:    nodeHash(l.new, r.new),
+			})
+		}
+	}
+
+	if len(folded) != 1 || folded[0].height != depth {
+		return [32]byte{}, [32]byte{}, errors.New("update does not cover the tree")
+	}
+
+	return folded[0].old, folded[0].new, nil
+}
+
+func parseClaim(body *cell.Cell) (*big.Int, *cell.Cell, error) {
+	s := body.BeginParse()
+
+	op, err := s.LoadUInt(32)
+	if err != nil {
+		return nil, nil, err
+	}
+	if op != contract.OP_CLAIM {
+		return nil, nil, fmt.Errorf("op %x is not a claim", op)
+	}
+
+	_, err = s.LoadUInt(64)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	index, err := s.LoadBigUInt(256)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	proofCell, err := loadRefCell(s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return index, proofCell, nil
+}
+
+// checkClaim walks the sibling chain of the proof from the leaf up and returns
+// the item data cell if it leads to root
+func checkClaim(index *big.Int, proofCell *cell.Cell, root [32]byte, depth int) (*cell.Cell, error) {
+	if index.BitLen() > depth {
+		return nil, fmt.Errorf("index %v does not fit in a tree of depth %v", index, depth)
+	}
+
+	s := proofCell.BeginParse()
+
+	itemCell, err := loadRefCell(s)
+	if err != nil {
+		return nil, err
+	}
+
+	chain, err := s.LoadRef()
+	if err != nil {
+		return nil, err
+	}
+
+	var h [32]byte
+	copy(h[:], itemCell.Hash())
+
+	for level := 0; level < depth; level++ {
+		sibling, err := loadHash(chain)
+		if err != nil {
+			return nil, fmt.Errorf("proof has only %v levels: %w", level, err)
+		}
+
+		if index.Bit(level) == 0 {
+			h = nodeHash(h, sibling)
+		} else {
+			h = nodeHash(sibling, h)
+		}
+
+		chain, err = chain.LoadRef()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if chain.BitsLeft() > 0 || chain.RefsNum() > 0 {
+		return nil, errors.New("proof has more levels than the tree")
+	}
+
+	if h != root {
+		return nil, errors.New("proof does not lead to the collection root")
+	}
+
+	return itemCell, nil
+}
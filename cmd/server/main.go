@@ -8,8 +8,13 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/rs/zerolog/log"
+	"github.com/ton-community/compressed-nft-api/claims"
 	"github.com/ton-community/compressed-nft-api/config"
+	"github.com/ton-community/compressed-nft-api/consistency"
+	"github.com/ton-community/compressed-nft-api/data"
 	myhttp "github.com/ton-community/compressed-nft-api/http"
+	"github.com/ton-community/compressed-nft-api/metadata"
 	"github.com/ton-community/compressed-nft-api/provider"
 	"github.com/ton-community/compressed-nft-api/provider/file"
 	"github.com/ton-community/compressed-nft-api/provider/pg"
@@ -19,6 +24,54 @@ import (
 	"github.com/xssnick/tonutils-go/address"
 )
 
+func checkDepth(s *types.State, sp provider.StateProvider, up updates.Recorder, depth int) error {
+	if s.Version == 0 {
+		return nil
+	}
+
+	if s.Depth == 0 {
+		stateDepth, err := consistency.StateDepth(s, up)
+		if err != nil {
+			return err
+		}
+
+		log.Warn().Int("depth", stateDepth).Msg("state has no depth recorded, using the one from the create update")
+
+		s.Depth = stateDepth
+		err = sp.SetState(s)
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.Depth != depth {
+		return fmt.Errorf("configured DEPTH is %v but the collection was created with depth %v; proofs would never verify, set DEPTH=%v", depth, s.Depth, s.Depth)
+	}
+
+	return nil
+}
+
+func checkConsistency(s *types.State, np provider.NodeProvider, ip provider.ItemProvider, strict bool) error {
+	problems, err := consistency.Check(s, np, ip, config.Config.Toncenter)
+	if err != nil {
+		if strict {
+			return err
+		}
+		log.Err(err).Msg("could not check consistency")
+		return nil
+	}
+
+	for _, p := range problems {
+		log.Error().Msg(p.Error())
+	}
+
+	if strict && len(problems) > 0 {
+		return fmt.Errorf("found %v consistency problems, refusing to start with STRICT_CHECK enabled", len(problems))
+	}
+
+	return nil
+}
+
 func main() {
 	config.LoadConfig()
 
@@ -36,14 +89,49 @@ func main() {
 	var sp provider.StateProvider = &file.StateProvider{
 		Path: path.Join(config.Config.DataDir, "state.json"),
 	}
-	var ip provider.ItemProvider = pg.NewItemProvider(pool)
+	var ip provider.ItemProvider = pg.NewItemProvider(pool, config.Config.ContentTemplate)
 	var np provider.NodeProvider = pg.NewNodeProvider(pool)
+	var cp provider.CollectionProvider = pg.NewCollectionProvider(pool)
+	var clp provider.ClaimProvider = pg.NewClaimProvider(pool)
+	var ap provider.AttributeProvider = pg.NewAttributeProvider(pool)
+
+	matcher, err := data.NewContentMatcher(config.Config.ContentTemplate)
+	if err != nil {
+		panic(err)
+	}
+
+	var meta *metadata.Template
+	if config.Config.MetadataTemplate != "" {
+		meta, err = metadata.LoadTemplate(config.Config.MetadataTemplate)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	var up updates.Recorder = &updates.FileUpdateRecorder{
+		Base: path.Join(config.Config.DataDir, "upd"),
+	}
 
 	currentState, err := sp.GetState()
 	if err != nil {
 		panic(err)
 	}
 
+	err = checkDepth(currentState, sp, up, config.Config.Depth)
+	if err != nil {
+		panic(err)
+	}
+
+	err = consistency.CheckContentTemplate(cp, config.Config.ContentTemplate)
+	if err != nil {
+		panic(err)
+	}
+
+	err = checkConsistency(currentState, np, ip, config.Config.StrictCheck)
+	if err != nil {
+		panic(err)
+	}
+
 	stateHolder := state.NewStateHolder(currentState)
 
 	addrs := make(chan *address.Address, 16)
@@ -54,19 +142,24 @@ func main() {
 	}
 
 	go updates.Watcher(newStates, addrs, stateHolder, sp)
-
-	var up updates.Recorder = &updates.FileUpdateRecorder{
-		Base: path.Join(config.Config.DataDir, "upd"),
-	}
+	go claims.Tracker(config.Config.Toncenter, stateHolder, clp, config.Config.ClaimScanInterval)
 
 	handler := &myhttp.Handler{
 		StateProvider: sp,
 		ItemProvider:  ip,
 		NodeProvider:  np,
 
+		CollectionProvider: cp,
+		ClaimProvider:      clp,
+		AttributeProvider:  ap,
+
 		StateHolder: stateHolder,
 
-		Depth: config.Config.Depth,
+		Depth:           config.Config.Depth,
+		ContentTemplate: config.Config.ContentTemplate,
+		ContentMatcher:  matcher,
+
+		Metadata: meta,
 
 		NewStates: newStates,
 		Addresses: addrs,
@@ -2,10 +2,14 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"io/fs"
+	"time"
 
 	"github.com/caarlos0/env/v9"
 	"github.com/joho/godotenv"
+	"github.com/ton-community/compressed-nft-api/data"
+	"github.com/ton-community/compressed-nft-api/types"
 )
 
 var Config = struct {
@@ -16,6 +20,11 @@ var Config = struct {
 	Depth         int    `env:"DEPTH,notEmpty"`
 	DataDir       string `env:"DATA_DIR,notEmpty"`
 	Toncenter     string `env:"TONCENTER_URI,notEmpty"`
+	StrictCheck   bool   `env:"STRICT_CHECK"`
+
+	ClaimScanInterval time.Duration `env:"CLAIM_SCAN_INTERVAL" envDefault:"1m"`
+	ContentTemplate   string        `env:"CONTENT_TEMPLATE" envDefault:"{index}.json"`
+	MetadataTemplate  string        `env:"METADATA_TEMPLATE"`
 }{}
 
 func LoadConfig() {
@@ -26,4 +35,10 @@ func LoadConfig() {
 	if err := env.Parse(&Config); err != nil {
 		panic(err)
 	}
+	if Config.Depth < 1 || Config.Depth > types.MAX_DEPTH {
+		panic(fmt.Errorf("DEPTH must be between 1 and %v, got %v", types.MAX_DEPTH, Config.Depth))
+	}
+	if err := data.ValidateContentTemplate(Config.ContentTemplate); err != nil {
+		panic(fmt.Errorf("bad CONTENT_TEMPLATE: %w", err))
+	}
 }
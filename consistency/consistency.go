@@ -0,0 +1,117 @@
+package consistency
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/ton-community/compressed-nft-api/merkle"
+	"github.com/ton-community/compressed-nft-api/provider"
+	"github.com/ton-community/compressed-nft-api/toncenter"
+	"github.com/ton-community/compressed-nft-api/types"
+	"github.com/ton-community/compressed-nft-api/updates"
+)
+
+// StateDepth returns the depth the collection was created with. States written
+// before the depth was persisted have none, so it is read from the create
+// update instead.
+func StateDepth(s *types.State, up updates.Recorder) (int, error) {
+	if s.Depth != 0 || s.Version == 0 {
+		return s.Depth, nil
+	}
+
+	var c updates.Create
+	err := up.Load(1, &c)
+	if err != nil {
+		return 0, fmt.Errorf("state has no depth recorded and it could not be read from the create update: %w; run the server once so that it records the depth, or add it to state.json", err)
+	}
+
+	return c.Depth, nil
+}
+
+// Check compares the committed state with the nodes and items tables and, if
+// the collection address is known, with the root that Toncenter reports. The
+// state must have its depth, see StateDepth.
+// Mismatches are returned as problems, while the error is only set if a check
+// could not be performed at all.
+func Check(s *types.State, np provider.NodeProvider, ip provider.ItemProvider, toncenterURI string) ([]error, error) {
+	if s.Version == 0 {
+		return nil, nil
+	}
+
+	problems := make([]error, 0)
+
+	root, err := np.GetNode(1, s.Version)
+	if err != nil {
+		if err != provider.ErrNodeNotExist {
+			return nil, err
+		}
+		problems = append(problems, fmt.Errorf("nodes table has no root for committed version %v; restore the nodes table from a backup or rebuild it", s.Version))
+	} else if root != s.Root {
+		problems = append(problems, fmt.Errorf("nodes table has root %v for committed version %v, but state has %v; the nodes table or state.json belongs to a different collection or was modified", hex.EncodeToString(root.Hash[:]), s.Version, hex.EncodeToString(s.Root.Hash[:])))
+	}
+
+	count, err := ip.Count()
+	if err != nil {
+		return nil, err
+	}
+
+	if count < s.LastIndex+1 {
+		problems = append(problems, fmt.Errorf("items table has %v items, but committed state has last index %v; committed items were deleted, restore them from a backup", count, s.LastIndex))
+	}
+
+	if s.Address == nil {
+		return problems, nil
+	}
+
+	chainRoot, err := toncenter.GetMerkleRoot(toncenterURI, s.Address.Address)
+	if err != nil {
+		return nil, fmt.Errorf("could not get merkle root of %v: %w", s.Address.Address.String(), err)
+	}
+
+	if bytes.Equal(chainRoot, s.Root.Hash[:]) {
+		return problems, nil
+	}
+
+	pending, err := np.GetNode(1, s.Version+1)
+	if err != nil && err != provider.ErrNodeNotExist {
+		return nil, err
+	}
+
+	if err == nil && bytes.Equal(chainRoot, pending.Hash[:]) {
+		// rediscover rebuilds the pending version from all items, so it
+		// only reproduces the on-chain root if none were added since
+		pendingCount, err := merkle.NewTree(np, s.Depth, s.Version, s.LastIndex+1).CountAt(s.Version + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		if pendingCount == count {
+			problems = append(problems, fmt.Errorf("collection %v already has the root of pending version %v on chain; call /admin/rediscover so that it gets committed", s.Address.Address.String(), s.Version+1))
+		} else {
+			problems = append(problems, fmt.Errorf("collection %v has the root of an older pending update on chain: version %v with %v items, but the items table now has %v; /admin/rediscover would build a different root instead of committing it", s.Address.Address.String(), s.Version+1, pendingCount, count))
+		}
+	} else {
+		problems = append(problems, fmt.Errorf("collection %v has root %v on chain, but committed state has %v; check that state.json and the collection address belong to the same collection", s.Address.Address.String(), hex.EncodeToString(chainRoot), hex.EncodeToString(s.Root.Hash[:])))
+	}
+
+	return problems, nil
+}
+
+// CheckContentTemplate returns an error if the collection was saved with a
+// different content template, since every leaf would then hash differently
+func CheckContentTemplate(cp provider.CollectionProvider, template string) error {
+	coll, err := cp.GetCollection()
+	if err != nil {
+		if err == provider.ErrCollectionNotExist {
+			return nil
+		}
+		return err
+	}
+
+	if coll.ContentTemplate != template {
+		return fmt.Errorf("configured CONTENT_TEMPLATE is %q but the collection was saved with %q; proofs would never verify, set CONTENT_TEMPLATE=%v", template, coll.ContentTemplate, coll.ContentTemplate)
+	}
+
+	return nil
+}
@@ -0,0 +1,32 @@
+package contract
+
+import (
+	"encoding/hex"
+
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+var ItemCode *cell.Cell
+var CollectionCode *cell.Cell
+
+func init() {
+	itemCodeB, err := hex.DecodeString("b5ee9c7241020e010001dc000114ff00f4a413f4bcf2c80b0102016203020009a11f9fe0050202ce07040201200605001d00f232cfd633c58073c5b3327b5520003b3b513434cffe900835d27080269fc07e90350c04090408f80c1c165b5b60020120090800113e910c30003cb8536002cf0c8871c02497c0f83434c0c05c6c2497c0f83e903e900c7e800c5c75c87e800c7e800c1cea6d003c00812ce3850c1b088d148cb1c17cb865407e90350c0408fc00f801b4c7f4cfe08417f30f45148c2eb8c08c0d0d0d4d60840bf2c9a884aeb8c097c12103fcbc200b0a00727082108b77173505c8cbff5004cf1610248040708010c8cb055007cf165005fa0215cb6a12cb1fcb3f226eb39458cf17019132e201c901fb0002ac3210375e3240135135c705f2e191fa4021f001fa40d20031fa0020d749c200f2e2c4820afaf0801ba121945315a0a1de22d70b01c300209206a19136e220c2fff2e1922194102a375be30d0293303234e30d5502f0030d0c006a26f0018210d53276db103744006d71708010c8cb055007cf165005fa0215cb6a12cb1fcb3f226eb39458cf17019132e201c901fb00007c821005138d91c85009cf16500bcf16712449145446a0708010c8cb055007cf165005fa0215cb6a12cb1fcb3f226eb39458cf17019132e201c901fb001047a4bb9948")
+	if err != nil {
+		panic(err)
+	}
+
+	ItemCode, err = cell.FromBOC(itemCodeB)
+	if err != nil {
+		panic(err)
+	}
+
+	collectionCodeB, err := hex.DecodeString("b5ee9c72410225010002a5000114ff00f4a413f4bcf2c80b010201620d0202012006030201480504000db50d9e005f0830001bb60b7e005f08ba0fe03a861f08900201200c0702012009080015b4f47e005f087e00fe01100201480b0a001daf6bf8017c23686987e987fd2018400017ae9ff8017c23e86983ea1840002db8b5d31f002f845d0d431d430d071c8cb0701cf16ccc980202cc170e020120100f0055d37803837c6384b7c2152a9085ccdae37c09078020937c600d274187c217805fc20895d797032fc30f801c02012014110201201312005b00b434800067f48034ffcc0064db084838009be040783508e955088c3c02c0b50c0129510c3c02d67c01167c012000413e1048be403e1089440d007c017cb8197c01a0827270e0321400f3c5b3327c02600201201615003d3e10c4fc01c83c021de0063232c15633c59400fe8084b2daf333325c7ec020001b3e401c1d3232c0b281f2fff274200201201f180201201c190201201b1a002d007232cffe0a33c5b25c083232c044fd003d0032c03260000b343e90350c200201201e1d005d1c013424d4d06e638794c92b5c6c260835c2ffd4013c0125c835c2ffc53c013880f5d3340129013a040d17c1006ea00013007232fff2fff27e40200201202320020120222100393e11fe11be117e10fe10be107232fff2c1f33e1133c5b33333327b552000473b513434ffc07e1874c1c07e18b5007e18fe90007e1935007e1975007e19b5007e19f46001cb43322c700925f03e0d0d3030171b0925f03e0fa4030f00202d31fd33f2282093a3ca6ba8e19345b82100510ff40bef2e066d401d001d3ff3001d4d430f00ae03321820a3cd52cba9d5bf84412c705f2e064d430f00ce0328210693d3950bae3025b840ff2f0824004cf846d08210a8cb00ad708010c8cb055005cf1624fa0214cb6a13cb1fcb3f01cf16c98040fb00829fb365")
+	if err != nil {
+		panic(err)
+	}
+
+	CollectionCode, err = cell.FromBOC(collectionCodeB)
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,190 @@
+package contract
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ton-community/compressed-nft-api/types"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+const API_VERSION = 1
+
+const DEPLOY_AMOUNT = 50000000
+
+var ErrInvalidRoyalty = errors.New("invalid royalty params")
+
+type CollectionParams struct {
+	Owner            *address.Address
+	CollectionMeta   string
+	CommonItemMeta   string
+	RoyaltyBase      uint64
+	RoyaltyFactor    uint64
+	RoyaltyRecipient *address.Address
+	APILink          string
+}
+
+func (p *CollectionParams) Validate() error {
+	if p.Owner == nil || p.RoyaltyRecipient == nil {
+		return errors.New("owner and royalty recipient must be set")
+	}
+
+	if p.RoyaltyBase > p.RoyaltyFactor || p.RoyaltyFactor == 0 || p.RoyaltyFactor > 0xffff {
+		return ErrInvalidRoyalty
+	}
+
+	return nil
+}
+
+func (p *CollectionParams) contentCell() *cell.Cell {
+	return cell.BeginCell().
+		MustStoreRef(
+			cell.BeginCell().
+				MustStoreUInt(0x01, 8).
+				MustStoreStringSnake(p.CollectionMeta).
+				EndCell(),
+		).
+		MustStoreRef(
+			cell.BeginCell().
+				MustStoreStringSnake(p.CommonItemMeta).
+				EndCell(),
+		).
+		EndCell()
+}
+
+func (p *CollectionParams) royaltyCell() *cell.Cell {
+	return cell.BeginCell().
+		MustStoreUInt(p.RoyaltyBase, 16).
+		MustStoreUInt(p.RoyaltyFactor, 16).
+		MustStoreAddr(p.RoyaltyRecipient).
+		EndCell()
+}
+
+func (p *CollectionParams) apiDataCell() *cell.Cell {
+	return cell.BeginCell().
+		MustStoreUInt(API_VERSION, 8).
+		MustStoreRef(
+			cell.BeginCell().
+				MustStoreStringSnake(p.APILink).
+				EndCell(),
+		).
+		EndCell()
+}
+
+// CollectionStateInit builds the state init of a collection with the given
+// initial root and depth
+func CollectionStateInit(root types.Node, depth int, p *CollectionParams) (*cell.Cell, error) {
+	err := p.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	dataCell := cell.BeginCell().
+		MustStoreSlice(root.Hash[:], types.NODE_LENGTH*8).
+		MustStoreUInt(uint64(depth), 8).
+		MustStoreRef(ItemCode).
+		MustStoreAddr(p.Owner).
+		MustStoreRef(p.contentCell()).
+		MustStoreRef(p.royaltyCell()).
+		MustStoreRef(p.apiDataCell()).
+		EndCell()
+
+	stateInit := &tlb.StateInit{
+		Code: CollectionCode,
+		Data: dataCell,
+	}
+
+	return tlb.ToCell(stateInit)
+}
+
+func AddressOf(stateInit *cell.Cell) *address.Address {
+	return address.NewAddress(0, 0, stateInit.Hash())
+}
+
+// NewCollection builds the state init and computes the address of a
+// collection with the given params
+func NewCollection(root types.Node, depth int, p *CollectionParams) (*types.Collection, error) {
+	stateInit, err := CollectionStateInit(root, depth, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.Collection{
+		Owner:            p.Owner,
+		CollectionMeta:   p.CollectionMeta,
+		CommonItemMeta:   p.CommonItemMeta,
+		RoyaltyBase:      p.RoyaltyBase,
+		RoyaltyFactor:    p.RoyaltyFactor,
+		RoyaltyRecipient: p.RoyaltyRecipient,
+		APILink:          p.APILink,
+		Depth:            depth,
+		Root:             root,
+		Address:          AddressOf(stateInit),
+		StateInit:        stateInit,
+	}, nil
+}
+
+// CollectionData is the part of the collection storage that changes or gets
+// checked when handling messages
+type CollectionData struct {
+	Root     types.Node
+	Depth    int
+	ItemCode *cell.Cell
+	Owner    *address.Address
+}
+
+func ParseCollectionStateInit(stateInit *cell.Cell) (*CollectionData, error) {
+	var si tlb.StateInit
+	err := tlb.LoadFromCell(&si, stateInit.BeginParse())
+	if err != nil {
+		return nil, err
+	}
+
+	if si.Code == nil || si.Data == nil {
+		return nil, errors.New("state init has no code or data")
+	}
+
+	if string(si.Code.Hash()) != string(CollectionCode.Hash()) {
+		return nil, errors.New("state init does not have the collection code")
+	}
+
+	s := si.Data.BeginParse()
+
+	root, err := s.LoadSlice(types.NODE_LENGTH * 8)
+	if err != nil {
+		return nil, err
+	}
+
+	depth, err := s.LoadUInt(8)
+	if err != nil {
+		return nil, err
+	}
+
+	itemCode, err := s.LoadRef()
+	if err != nil {
+		return nil, err
+	}
+
+	itemCodeCell, err := itemCode.ToCell()
+	if err != nil {
+		return nil, err
+	}
+
+	owner, err := s.LoadAddr()
+	if err != nil {
+		return nil, err
+	}
+
+	if depth < 1 || depth > types.MAX_DEPTH {
+		return nil, fmt.Errorf("unsupported depth %v", depth)
+	}
+
+	return &CollectionData{
+		Root:     types.NewNode(root),
+		Depth:    int(depth),
+		ItemCode: itemCodeCell,
+		Owner:    owner,
+	}, nil
+}
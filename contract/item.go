@@ -0,0 +1,88 @@
+package contract
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+const OP_CLAIM = 0x13a3ca6
+
+// CLAIM_AMOUNT is the smallest value the collection accepts with a claim
+const CLAIM_AMOUNT = 85000000
+
+// ItemStateInit builds the state init that the collection deploys an item
+// with
+func ItemStateInit(collection *address.Address, index uint64) (*cell.Cell, error) {
+	stateInit := &tlb.StateInit{
+		Code: ItemCode,
+		Data: cell.BeginCell().
+			MustStoreUInt(index, 64).
+			MustStoreAddr(collection).
+			EndCell(),
+	}
+
+	return tlb.ToCell(stateInit)
+}
+
+func ItemAddress(collection *address.Address, index uint64) (*address.Address, error) {
+	stateInit, err := ItemStateInit(collection, index)
+	if err != nil {
+		return nil, err
+	}
+
+	return AddressOf(stateInit), nil
+}
+
+// ClaimBody builds the message body that asks the collection to deploy the
+// item proven by the proof cell
+func ClaimBody(index uint64, proofCell *cell.Cell, queryID uint64) *cell.Cell {
+	return cell.BeginCell().
+		MustStoreUInt(OP_CLAIM, 32).
+		MustStoreUInt(queryID, 64).
+		MustStoreBigUInt(new(big.Int).SetUint64(index), 256).
+		MustStoreRef(proofCell).
+		EndCell()
+}
+
+func ParseClaimBody(body *cell.Cell) (uint64, *cell.Cell, error) {
+	s := body.BeginParse()
+
+	op, err := s.LoadUInt(32)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if op != OP_CLAIM {
+		return 0, nil, fmt.Errorf("unexpected op %x", op)
+	}
+
+	_, err = s.LoadUInt(64)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	index, err := s.LoadBigUInt(256)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if !index.IsUint64() {
+		return 0, nil, fmt.Errorf("index %v is too large", index)
+	}
+
+	proofCell, err := s.LoadRef()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	proofCellCell, err := proofCell.ToCell()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return index.Uint64(), proofCellCell, nil
+}
@@ -0,0 +1,227 @@
+package contract
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/ton-community/compressed-nft-api/hash"
+	"github.com/ton-community/compressed-nft-api/types"
+	"github.com/ton-community/compressed-nft-api/updates"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+const OP_UPDATE = 0x23cd52c
+
+const UPDATE_AMOUNT = 150000000
+
+type updateCellElement struct {
+	node   []byte
+	update bool
+}
+
+func buildUpdateCell(m map[uint64]updateCellElement, index uint64, height int, used *int) (*cell.Cell, error) {
+	if e, ok := m[index]; ok {
+		*used++
+		return cell.BeginCell().
+			MustStoreBoolBit(true).
+			MustStoreBoolBit(!e.update).
+			MustStoreSlice(e.node, 256).
+			EndCell(), nil
+	}
+
+	if height == 0 {
+		return nil, fmt.Errorf("no node covers leaf %v", index)
+	}
+
+	left, err := buildUpdateCell(m, 2*index, height-1, used)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := buildUpdateCell(m, 2*index+1, height-1, used)
+	if err != nil {
+		return nil, err
+	}
+
+	return cell.BeginCell().
+		MustStoreBoolBit(false).
+		MustStoreRef(left).
+		MustStoreRef(right).
+		EndCell(), nil
+}
+
+// BuildUpdateCell builds the pruned tree that the collection uses to check the
+// old root and to compute the new one. The updated and provided nodes must
+// cover the tree of the given depth exactly once
+func BuildUpdateCell(upd *updates.Update, depth int) (*cell.Cell, error) {
+	m := map[uint64]updateCellElement{}
+
+	for _, u := range upd.Updates {
+		if u.Node == nil {
+			return nil, fmt.Errorf("update of node %v has no hash", u.Index)
+		}
+		if _, ok := m[u.Index]; ok {
+			return nil, fmt.Errorf("node %v is listed more than once", u.Index)
+		}
+		m[u.Index] = updateCellElement{
+			node:   u.Node.Hash[:],
+			update: true,
+		}
+	}
+
+	for i, h := range upd.Hashes {
+		if h == nil {
+			return nil, fmt.Errorf("provided node %v has no hash", i)
+		}
+		if _, ok := m[i]; ok {
+			return nil, fmt.Errorf("node %v is listed more than once", i)
+		}
+		m[i] = updateCellElement{
+			node:   h.Hash[:],
+			update: false,
+		}
+	}
+
+	used := 0
+	c, err := buildUpdateCell(m, 1, depth, &used)
+	if err != nil {
+		return nil, err
+	}
+
+	if used != len(m) {
+		return nil, fmt.Errorf("%v nodes are outside of the tree or covered by other nodes", len(m)-used)
+	}
+
+	return c, nil
+}
+
+func UpdateBody(upd *updates.Update, depth int) (*cell.Cell, error) {
+	updateCell, err := BuildUpdateCell(upd, depth)
+	if err != nil {
+		return nil, err
+	}
+
+	return updateBody(updateCell), nil
+}
+
+func updateBody(updateCell *cell.Cell) *cell.Cell {
+	return cell.BeginCell().
+		MustStoreUInt(OP_UPDATE, 32).
+		MustStoreUInt(0, 64).
+		MustStoreRef(updateCell).
+		EndCell()
+}
+
+// UpdateRoots evaluates an update cell the way the collection does: updated
+// subtrees count as empty in the old root and as their new hash in the new
+// root, while provided hashes are used as is in both
+func UpdateRoots(updateCell *cell.Cell, depth int) (types.Node, types.Node, error) {
+	return updateRoots(updateCell.BeginParse(), depth)
+}
+
+func updateRoots(s *cell.Slice, height int) (types.Node, types.Node, error) {
+	leaf, err := s.LoadBoolBit()
+	if err != nil {
+		return types.Node{}, types.Node{}, err
+	}
+
+	if leaf {
+		provided, err := s.LoadBoolBit()
+		if err != nil {
+			return types.Node{}, types.Node{}, err
+		}
+
+		b, err := s.LoadSlice(types.NODE_LENGTH * 8)
+		if err != nil {
+			return types.Node{}, types.Node{}, err
+		}
+
+		node := types.NewNode(b)
+		if provided {
+			return node, node, nil
+		}
+
+		return hash.ZeroNode(height), node, nil
+	}
+
+	if height == 0 {
+		return types.Node{}, types.Node{}, errors.New("update cell is deeper than the tree")
+	}
+
+	left, err := s.LoadRef()
+	if err != nil {
+		return types.Node{}, types.Node{}, err
+	}
+
+	right, err := s.LoadRef()
+	if err != nil {
+		return types.Node{}, types.Node{}, err
+	}
+
+	lo, ln, err := updateRoots(left, height-1)
+	if err != nil {
+		return types.Node{}, types.Node{}, err
+	}
+
+	ro, rn, err := updateRoots(right, height-1)
+	if err != nil {
+		return types.Node{}, types.Node{}, err
+	}
+
+	return hash.Nodes(lo, ro), hash.Nodes(ln, rn), nil
+}
+
+// CheckUpdate builds the body of an update and checks that it applies to the
+// given root and leads to the root recorded in the update
+func CheckUpdate(upd *updates.Update, depth int, root types.Node) (*cell.Cell, error) {
+	recorded, err := hex.DecodeString(upd.Root)
+	if err != nil || len(recorded) != types.NODE_LENGTH {
+		return nil, errors.New("invalid root")
+	}
+
+	updateCell, err := BuildUpdateCell(upd, depth)
+	if err != nil {
+		return nil, err
+	}
+
+	oldRoot, newRoot, err := UpdateRoots(updateCell, depth)
+	if err != nil {
+		return nil, err
+	}
+
+	if oldRoot != root {
+		return nil, fmt.Errorf("update applies to root %v, but the committed root is %v; the update is stale or has been tampered with", hex.EncodeToString(oldRoot.Hash[:]), hex.EncodeToString(root.Hash[:]))
+	}
+
+	if newRoot != types.NewNode(recorded) {
+		return nil, fmt.Errorf("update produces root %v, but the recorded one is %v", hex.EncodeToString(newRoot.Hash[:]), upd.Root)
+	}
+
+	return updateBody(updateCell), nil
+}
+
+func ParseUpdateBody(body *cell.Cell) (*cell.Cell, error) {
+	s := body.BeginParse()
+
+	op, err := s.LoadUInt(32)
+	if err != nil {
+		return nil, err
+	}
+
+	if op != OP_UPDATE {
+		return nil, fmt.Errorf("unexpected op %x", op)
+	}
+
+	_, err = s.LoadUInt(64)
+	if err != nil {
+		return nil, err
+	}
+
+	updateCell, err := s.LoadRef()
+	if err != nil {
+		return nil, err
+	}
+
+	return updateCell.ToCell()
+}
@@ -0,0 +1,99 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+// DEFAULT_CONTENT_TEMPLATE gives items the "<index>.json" individual content
+const DEFAULT_CONTENT_TEMPLATE = "{index}.json"
+
+// MAX_INDEX_WIDTH is enough to hold any uint64 index
+const MAX_INDEX_WIDTH = 20
+
+var placeholder = regexp.MustCompile(`\{index(?::(\d+))?\}`)
+
+// ValidateContentTemplate checks that a template has at least one {index}
+// placeholder and that the placeholders are well formed. {index} is replaced
+// by the decimal index and {index:N} by the index zero-padded to N digits.
+func ValidateContentTemplate(t string) error {
+	matches := placeholder.FindAllStringSubmatch(t, -1)
+	if len(matches) == 0 {
+		return errors.New("content template must contain an {index} placeholder")
+	}
+
+	for _, m := range matches {
+		if m[1] == "" {
+			continue
+		}
+
+		width, err := strconv.Atoi(m[1])
+		if err != nil || width < 1 || width > MAX_INDEX_WIDTH {
+			return fmt.Errorf("bad placeholder %v, the width must be between 1 and %v", m[0], MAX_INDEX_WIDTH)
+		}
+	}
+
+	return nil
+}
+
+func RenderContent(t string, index uint64) string {
+	return placeholder.ReplaceAllStringFunc(t, func(p string) string {
+		m := placeholder.FindStringSubmatch(p)
+		if m[1] == "" {
+			return strconv.FormatUint(index, 10)
+		}
+
+		width, _ := strconv.Atoi(m[1])
+
+		return fmt.Sprintf("%0*d", width, index)
+	})
+}
+
+// ContentCell returns the individual content of an item rendered from the
+// template
+func ContentCell(t string, index uint64) *cell.Cell {
+	return cell.BeginCell().MustStoreStringSnake(RenderContent(t, index)).EndCell()
+}
+
+// ContentMatcher finds the index whose content rendered from a template is a
+// given string
+type ContentMatcher struct {
+	template string
+	re       *regexp.Regexp
+}
+
+func NewContentMatcher(t string) (*ContentMatcher, error) {
+	err := ValidateContentTemplate(t)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := placeholder.Split(t, -1)
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+
+	return &ContentMatcher{
+		template: t,
+		re:       regexp.MustCompile("^" + strings.Join(parts, `(\d+)`) + "$"),
+	}, nil
+}
+
+func (m *ContentMatcher) Match(s string) (uint64, bool) {
+	sm := m.re.FindStringSubmatch(s)
+	if len(sm) < 2 {
+		return 0, false
+	}
+
+	index, err := strconv.ParseUint(sm[1], 10, 64)
+	if err != nil || RenderContent(m.template, index) != s {
+		return 0, false
+	}
+
+	return index, true
+}
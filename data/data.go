@@ -2,6 +2,7 @@ package data
 
 import (
 	"strconv"
+	"time"
 
 	"github.com/ton-community/compressed-nft-api/address"
 	"github.com/ton-community/compressed-nft-api/types"
@@ -17,6 +18,30 @@ func (d *ItemMetadata) ToCell() *cell.Cell {
 	return cell.BeginCell().MustStoreAddr(d.Owner.Address).MustStoreRef(d.IndividualContent).EndCell()
 }
 
+func ParseItemMetadata(c *cell.Cell) (*ItemMetadata, error) {
+	s := c.BeginParse()
+
+	owner, err := s.LoadAddr()
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := s.LoadRef()
+	if err != nil {
+		return nil, err
+	}
+
+	contentCell, err := content.ToCell()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ItemMetadata{
+		Owner:             &address.Address{Address: owner},
+		IndividualContent: contentCell,
+	}, nil
+}
+
 func (d *ItemMetadata) ToNode() types.Node {
 	return types.NewNode(d.ToCell().Hash())
 }
@@ -25,6 +50,9 @@ type ItemData struct {
 	Metadata *ItemMetadata `json:"metadata"`
 	DataCell *cell.Cell    `json:"data_cell"`
 	Index    string        `json:"index"`
+
+	Claimed   bool       `json:"claimed"`
+	ClaimedAt *time.Time `json:"claimed_at,omitempty"`
 }
 
 func NewItemData(index uint64, metadata *ItemMetadata) *ItemData {
@@ -34,3 +62,8 @@ func NewItemData(index uint64, metadata *ItemMetadata) *ItemData {
 		Index:    strconv.FormatUint(index, 10),
 	}
 }
+
+func (d *ItemData) SetClaimed(at time.Time) {
+	d.Claimed = true
+	d.ClaimedAt = &at
+}
@@ -9,8 +9,10 @@ require (
 	github.com/joho/godotenv v1.5.1
 	github.com/labstack/echo/v4 v4.10.2
 	github.com/rs/zerolog v1.29.1
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	github.com/spf13/cobra v1.7.0
 	github.com/xssnick/tonutils-go v1.7.4
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
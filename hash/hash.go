@@ -1,17 +1,26 @@
 package hash
 
 import (
+	"sync"
+
 	"github.com/ton-community/compressed-nft-api/types"
 	"github.com/xssnick/tonutils-go/tvm/cell"
 )
 
-var ZeroNodes []types.Node
+var zeroNodesMu sync.Mutex
+var zeroNodes = []types.Node{types.NewNode(make([]byte, types.NODE_LENGTH))}
+
+// ZeroNode returns the hash of an empty subtree of the given height
+func ZeroNode(height int) types.Node {
+	zeroNodesMu.Lock()
+	defer zeroNodesMu.Unlock()
 
-func init() {
-	ZeroNodes = append(ZeroNodes, types.NewNode(make([]byte, 32)))
-	for i := 1; i < types.MAX_LEVELS; i++ {
-		ZeroNodes = append(ZeroNodes, Nodes(ZeroNodes[i-1], ZeroNodes[i-1]))
+	for len(zeroNodes) <= height {
+		prev := zeroNodes[len(zeroNodes)-1]
+		zeroNodes = append(zeroNodes, Nodes(prev, prev))
 	}
+
+	return zeroNodes[height]
 }
 
 func Nodes(a, b types.Node) types.Node {
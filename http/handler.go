@@ -2,18 +2,26 @@ package http
 
 import (
 	"encoding/hex"
+	"encoding/json"
 	"errors"
-	"math/bits"
+	"fmt"
+	"io/fs"
+	"math/big"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/rs/zerolog/log"
 	myaddress "github.com/ton-community/compressed-nft-api/address"
+	"github.com/ton-community/compressed-nft-api/contract"
 	"github.com/ton-community/compressed-nft-api/data"
-	"github.com/ton-community/compressed-nft-api/hash"
+	"github.com/ton-community/compressed-nft-api/merkle"
+	"github.com/ton-community/compressed-nft-api/metadata"
+	"github.com/ton-community/compressed-nft-api/proof"
 	"github.com/ton-community/compressed-nft-api/provider"
 	"github.com/ton-community/compressed-nft-api/state"
+	"github.com/ton-community/compressed-nft-api/transfer"
 	"github.com/ton-community/compressed-nft-api/types"
 	"github.com/ton-community/compressed-nft-api/updates"
 	"github.com/xssnick/tonutils-go/address"
@@ -22,14 +30,31 @@ import (
 
 const ITEMS_LIMIT = 10000
 
+const OWNER_ITEMS_LIMIT = 1000
+
+// proofs are computed one by one, so fewer of them are returned per page
+const OWNER_PROOFS_LIMIT = 100
+
+// wallets accept at most 4 messages in one TonConnect request
+const CLAIMS_PER_TRANSACTION = 4
+
 type Handler struct {
 	StateProvider provider.StateProvider
 	NodeProvider  provider.NodeProvider
 	ItemProvider  provider.ItemProvider
 
+	CollectionProvider provider.CollectionProvider
+	ClaimProvider      provider.ClaimProvider
+	AttributeProvider  provider.AttributeProvider
+
 	StateHolder *state.StateHolder
 
-	Depth int
+	Depth           int
+	ContentTemplate string
+	ContentMatcher  *data.ContentMatcher
+
+	// Metadata is nil unless metadata hosting is enabled
+	Metadata *metadata.Template
 
 	NewStates chan *types.State
 	Addresses chan *address.Address
@@ -60,9 +85,18 @@ func (h *Handler) getItemsInternal(from uint64, count uint64) (*ItemsResponse, e
 		to = uint64(len(items))
 	}
 
+	claims, err := h.ClaimProvider.GetClaims(from, to)
+	if err != nil {
+		return nil, err
+	}
+
 	fi := make([]*data.ItemData, 0, int(to))
 	for i := uint64(0); i < to; i++ {
-		fi = append(fi, data.NewItemData(i+from, items[i]))
+		d := data.NewItemData(i+from, items[i])
+		if at, ok := claims[i+from]; ok {
+			d.SetClaimed(at)
+		}
+		fi = append(fi, d)
 	}
 
 	return &ItemsResponse{
@@ -110,43 +144,42 @@ func (h *Handler) getItems(c echo.Context) error {
 
 const NODE_DICT_KEY_LEN = 32
 
+func (h *Handler) tree(s *types.State) *merkle.Tree {
+	count := uint64(0)
+	if s.Version > 0 {
+		count = s.LastIndex + 1
+	}
+
+	return merkle.NewTree(h.NodeProvider, h.Depth, s.Version, count)
+}
+
 func (h *Handler) getItemInternal(state *state.FullState, index uint64) (*ItemResponse, error) {
 	ip := h.ItemProvider
-	np := h.NodeProvider
-	depth := h.Depth
 
 	item, err := ip.GetItem(index)
 	if err != nil {
 		return nil, err
 	}
 
-	nodes := make([]*cell.Builder, 0)
-	nodeIndex := uint64(1<<depth) + index
-	for i := 0; i < depth; i++ {
-		nodeIndex ^= 1
-		node, err := np.GetNode(nodeIndex, state.CurrentState.Version)
-		if err != nil {
-			if err == provider.ErrNodeNotExist {
-				node = hash.ZeroNodes[i]
-			} else {
-				return nil, err
-			}
-		}
-
-		nodes = append(nodes, cell.BeginCell().MustStoreSlice(node.Hash[:], 256))
+	siblings, err := h.tree(state.CurrentState).Proof(index, state.CurrentState.Version)
+	if err != nil {
+		return nil, err
+	}
 
-		nodeIndex >>= 1
+	claims, err := h.ClaimProvider.GetClaims(index, 1)
+	if err != nil {
+		return nil, err
 	}
 
-	tree := cell.BeginCell().EndCell()
-	for i := len(nodes) - 1; i >= 0; i-- {
-		tree = nodes[i].MustStoreRef(tree).EndCell()
+	d := data.NewItemData(index, item)
+	if at, ok := claims[index]; ok {
+		d.SetClaimed(at)
 	}
 
 	return &ItemResponse{
-		Item:      data.NewItemData(index, item),
+		Item:      d,
 		Root:      state.CurrentState.Root,
-		ProofCell: cell.BeginCell().MustStoreRef(item.ToCell()).MustStoreRef(tree).EndCell(),
+		ProofCell: proof.Build(item.ToCell(), siblings),
 	}, nil
 }
 
@@ -185,267 +218,563 @@ func (h *Handler) getItem(c echo.Context) error {
 	return c.JSON(http.StatusOK, resp)
 }
 
-type StateResponse struct {
-	Depth     int                `json:"depth"`
-	Capacity  string             `json:"capacity"`
+type OwnerItemsRequest struct {
+	Address string `param:"address"`
+	From    uint64 `query:"from"`
+	Count   uint64 `query:"count"`
+	Proofs  bool   `query:"proofs"`
+}
+
+type OwnerItemsResponse struct {
+	Owner     *myaddress.Address `json:"owner"`
+	Indices   []string           `json:"indices"`
+	Items     []*ItemResponse    `json:"items,omitempty"`
+	Next      string             `json:"next,omitempty"`
 	LastIndex string             `json:"last_index"`
 	Root      types.Node         `json:"root"`
-	Address   *myaddress.Address `json:"address"`
 }
 
-func (h *Handler) getState(c echo.Context) error {
-	sh := h.StateHolder
+// getOwnerItems lists the committed items of an owner starting from the
+// index in from; next is set when there are more items to fetch
+func (h *Handler) getOwnerItems(c echo.Context) error {
+	or := new(OwnerItemsRequest)
+	if err := c.Bind(or); err != nil {
+		log.Err(err).Msg("bad owner items request")
+		return c.String(http.StatusBadRequest, "bad request")
+	}
 
-	state := sh.GetFullState()
+	owner, err := address.ParseAddr(or.Address)
+	if err != nil {
+		log.Err(err).Msg("bad owner address")
+		return c.String(http.StatusBadRequest, "bad address")
+	}
 
-	resp := &StateResponse{
-		Depth:     h.Depth,
-		Root:      state.CurrentState.Root,
-		Capacity:  strconv.Itoa(1 << h.Depth),
+	limit := uint64(OWNER_ITEMS_LIMIT)
+	if or.Proofs {
+		limit = OWNER_PROOFS_LIMIT
+	}
+	if or.Count == 0 || or.Count > limit {
+		or.Count = limit
+	}
+
+	state := h.StateHolder.GetFullState()
+
+	to := uint64(0)
+	if state.CurrentState.Version > 0 {
+		to = state.CurrentState.LastIndex + 1
+	}
+
+	indices, err := h.ItemProvider.GetOwnerItems(owner, or.From, to, or.Count+1)
+	if err != nil {
+		log.Err(err).Msg("could not get owner items")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	resp := &OwnerItemsResponse{
+		Owner:     &myaddress.Address{Address: owner},
+		Indices:   make([]string, 0, len(indices)),
 		LastIndex: strconv.FormatUint(state.CurrentState.LastIndex, 10),
-		Address:   &myaddress.Address{Address: state.CurrentState.Address.Address},
+		Root:      state.CurrentState.Root,
 	}
 
-	return c.JSON(http.StatusOK, resp)
-}
+	if uint64(len(indices)) > or.Count {
+		resp.Next = strconv.FormatUint(indices[or.Count], 10)
+		indices = indices[:or.Count]
+	}
 
-func setItemHashes(ip provider.ItemProvider, np provider.NodeProvider, depth int, from, to uint64, version int) error {
-	nodeIndexOffset := uint64(1 << depth)
-	for i := from; i <= to; i++ {
-		item, err := ip.GetItem(i)
-		if err != nil {
-			return err
+	for _, index := range indices {
+		resp.Indices = append(resp.Indices, strconv.FormatUint(index, 10))
+
+		if !or.Proofs {
+			continue
 		}
 
-		err = np.SetNode(i+nodeIndexOffset, version, item.ToNode())
+		item, err := h.getItemInternal(state, index)
 		if err != nil {
-			return err
+			log.Err(err).Msg("could not get item")
+			return c.NoContent(http.StatusInternalServerError)
 		}
+
+		resp.Items = append(resp.Items, item)
 	}
 
-	return nil
+	return c.JSON(http.StatusOK, resp)
 }
 
-func setNodes(np provider.NodeProvider, depth int, nodeFrom, nodeTo uint64, version int) error {
-	for d := depth - 1; d >= 0; d-- {
-		nodeFrom >>= 1
-		nodeTo >>= 1
+type ItemAddressResponse struct {
+	Index      string             `json:"index"`
+	Address    *myaddress.Address `json:"address"`
+	Collection *myaddress.Address `json:"collection"`
+}
 
-		for node := nodeFrom; node <= nodeTo; node++ {
-			nl, err := np.GetNode(2*node, version)
-			if err != nil {
-				if err == provider.ErrNodeNotExist {
-					nl = hash.ZeroNodes[depth-d-1]
-				} else {
-					return err
-				}
-			}
+func (h *Handler) getItemAddress(c echo.Context) error {
+	ir := new(ItemRequest)
+	if err := c.Bind(ir); err != nil {
+		log.Err(err).Msg("bad item address request")
+		return c.String(http.StatusBadRequest, "bad request")
+	}
 
-			nr, err := np.GetNode(2*node+1, version)
-			if err != nil {
-				if err == provider.ErrNodeNotExist {
-					nr = hash.ZeroNodes[depth-d-1]
-				} else {
-					return err
-				}
-			}
+	state := h.StateHolder.GetFullState()
 
-			err = np.SetNode(node, version, hash.Nodes(nl, nr))
-			if err != nil {
-				return err
-			}
-		}
+	if state.CurrentState.Address == nil {
+		return c.String(http.StatusConflict, "collection has not been deployed yet")
 	}
 
-	return nil
-}
+	if ir.Index >= uint64(1)<<h.Depth {
+		return c.String(http.StatusNotFound, "item index too large")
+	}
 
-func (h *Handler) discoverFirst(c echo.Context) error {
-	ip := h.ItemProvider
-	np := h.NodeProvider
-	depth := h.Depth
-	newStates := h.NewStates
-	upr := h.UpdateRecorder
+	collection := state.CurrentState.Address.Address
 
-	itemCount, err := ip.Count()
+	addr, err := contract.ItemAddress(collection, ir.Index)
 	if err != nil {
-		return err
+		log.Err(err).Msg("could not compute item address")
+		return c.NoContent(http.StatusInternalServerError)
 	}
 
-	version := 1
+	resp := &ItemAddressResponse{
+		Index:      strconv.FormatUint(ir.Index, 10),
+		Address:    &myaddress.Address{Address: addr},
+		Collection: &myaddress.Address{Address: collection},
+	}
 
-	err = setItemHashes(ip, np, depth, 0, itemCount-1, version)
-	if err != nil {
-		return err
+	return c.JSON(http.StatusOK, resp)
+}
+
+type ClaimResponse struct {
+	Index         string                      `json:"index"`
+	ItemAddress   *myaddress.Address          `json:"item_address"`
+	Destination   *myaddress.Address          `json:"destination"`
+	Amount        string                      `json:"amount"`
+	Body          *cell.Cell                  `json:"body"`
+	Root          types.Node                  `json:"root"`
+	Link          string                      `json:"link"`
+	TonkeeperLink string                      `json:"tonkeeper_link"`
+	TonConnect    *transfer.TonConnectRequest `json:"tonconnect"`
+}
+
+// claims are sent to the collection, which checks the proof and deploys the
+// item
+func claimMessage(collection *address.Address, index uint64, item *ItemResponse) *transfer.Message {
+	return &transfer.Message{
+		Destination: collection,
+		Amount:      contract.CLAIM_AMOUNT,
+		Body:        contract.ClaimBody(index, item.ProofCell, 0),
+	}
+}
+
+func (h *Handler) getClaim(c echo.Context) error {
+	ir := new(ItemRequest)
+	if err := c.Bind(ir); err != nil {
+		log.Err(err).Msg("bad claim request")
+		return c.String(http.StatusBadRequest, "bad request")
+	}
+
+	state := h.StateHolder.GetFullState()
+
+	if state.CurrentState.Address == nil {
+		return c.String(http.StatusConflict, "collection has not been deployed yet")
+	}
+
+	if ir.Index > state.CurrentState.LastIndex {
+		log.Error().Msg("item index too large")
+		return c.String(http.StatusNotFound, "item index too large")
 	}
 
-	err = setNodes(np, depth, uint64(1<<depth), uint64(1<<depth)-1+itemCount, version)
+	item, err := h.getItemInternal(state, ir.Index)
 	if err != nil {
-		return err
+		log.Err(err).Msg("could not get item")
+		return c.NoContent(http.StatusInternalServerError)
 	}
 
-	root, err := np.GetNode(1, version)
+	if item.Item.Claimed {
+		return c.String(http.StatusConflict, "item has already been claimed")
+	}
+
+	collection := state.CurrentState.Address.Address
+
+	itemAddress, err := contract.ItemAddress(collection, ir.Index)
 	if err != nil {
-		return err
+		log.Err(err).Msg("could not compute item address")
+		return c.NoContent(http.StatusInternalServerError)
 	}
 
-	state := &types.State{
-		LastIndex: itemCount - 1,
-		Version:   version,
-		Root:      root,
+	msg := claimMessage(collection, ir.Index, item)
+
+	resp := &ClaimResponse{
+		Index:         item.Item.Index,
+		ItemAddress:   &myaddress.Address{Address: itemAddress},
+		Destination:   &myaddress.Address{Address: collection},
+		Amount:        strconv.FormatUint(msg.Amount, 10),
+		Body:          msg.Body,
+		Root:          item.Root,
+		Link:          msg.Deeplink(),
+		TonkeeperLink: msg.TonkeeperLink(),
+		TonConnect:    transfer.TonConnect(msg),
 	}
 
-	newStates <- state
+	return c.JSON(http.StatusOK, resp)
+}
 
-	var upd updates.Create
-	upd.Type = "create"
-	upd.Root = hex.EncodeToString(state.Root.Hash[:])
-	upd.Depth = h.Depth
-	upd.LastIndex = state.LastIndex
+type OwnerClaimRequest struct {
+	Address        string `param:"address"`
+	From           uint64 `query:"from"`
+	PerTransaction int    `query:"per_transaction"`
+}
 
-	err = upr.Record(upd, state.Version)
+type ClaimTransaction struct {
+	Indices    []string                    `json:"indices"`
+	Amount     string                      `json:"amount"`
+	TonConnect *transfer.TonConnectRequest `json:"tonconnect"`
+}
 
-	return err
+type OwnerClaimResponse struct {
+	Owner        *myaddress.Address  `json:"owner"`
+	Destination  *myaddress.Address  `json:"destination"`
+	Transactions []*ClaimTransaction `json:"transactions"`
+	Claimed      []string            `json:"claimed"`
+	Next         string              `json:"next,omitempty"`
+	Root         types.Node          `json:"root"`
 }
 
-func getNodesToUpdate(start, end uint64, depth int, cn uint64, cd int) []uint64 {
-	cns := cn << (depth - cd)
-	cne := cns + (1 << (depth - cd)) - 1
+// getOwnerClaim groups the claims of the unclaimed items of an owner into
+// TonConnect requests; items known to be claimed are listed in claimed
+func (h *Handler) getOwnerClaim(c echo.Context) error {
+	or := new(OwnerClaimRequest)
+	if err := c.Bind(or); err != nil {
+		log.Err(err).Msg("bad owner claim request")
+		return c.String(http.StatusBadRequest, "bad request")
+	}
 
-	if start > cne || end < cns {
-		return nil
+	owner, err := address.ParseAddr(or.Address)
+	if err != nil {
+		log.Err(err).Msg("bad owner address")
+		return c.String(http.StatusBadRequest, "bad address")
 	}
 
-	if cns >= start && cne <= end {
-		return []uint64{cn}
+	if or.PerTransaction <= 0 || or.PerTransaction > CLAIMS_PER_TRANSACTION {
+		or.PerTransaction = CLAIMS_PER_TRANSACTION
 	}
 
-	left := getNodesToUpdate(start, end, depth, 2*cn, cd+1)
-	right := getNodesToUpdate(start, end, depth, 2*cn+1, cd+1)
+	state := h.StateHolder.GetFullState()
 
-	return append(left, right...)
-}
+	if state.CurrentState.Address == nil {
+		return c.String(http.StatusConflict, "collection has not been deployed yet")
+	}
 
-func getNodesToProvide(nodes []uint64) []uint64 {
-	d := map[uint64]byte{}
-	for _, n := range nodes {
-		d[n] = 1
-		nn := n ^ 1
-		if _, ok := d[nn]; !ok {
-			d[nn] = 2
-		}
+	to := uint64(0)
+	if state.CurrentState.Version > 0 {
+		to = state.CurrentState.LastIndex + 1
 	}
 
-	for _, n := range nodes {
-		n >>= 1
-		for n > 1 {
-			d[n] = 3
-			nn := n ^ 1
-			if _, ok := d[nn]; !ok {
-				d[nn] = 2
-			}
-			n >>= 1
+	indices, err := h.ItemProvider.GetOwnerItems(owner, or.From, to, OWNER_PROOFS_LIMIT+1)
+	if err != nil {
+		log.Err(err).Msg("could not get owner items")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	collection := state.CurrentState.Address.Address
+
+	resp := &OwnerClaimResponse{
+		Owner:        &myaddress.Address{Address: owner},
+		Destination:  &myaddress.Address{Address: collection},
+		Transactions: []*ClaimTransaction{},
+		Claimed:      []string{},
+		Root:         state.CurrentState.Root,
+	}
+
+	if len(indices) > OWNER_PROOFS_LIMIT {
+		resp.Next = strconv.FormatUint(indices[OWNER_PROOFS_LIMIT], 10)
+		indices = indices[:OWNER_PROOFS_LIMIT]
+	}
+
+	var msgs []*transfer.Message
+	var batch []string
+	flush := func() {
+		if len(msgs) == 0 {
+			return
+		}
+
+		amount := uint64(0)
+		for _, m := range msgs {
+			amount += m.Amount
 		}
+
+		resp.Transactions = append(resp.Transactions, &ClaimTransaction{
+			Indices:    batch,
+			Amount:     strconv.FormatUint(amount, 10),
+			TonConnect: transfer.TonConnect(msgs...),
+		})
+
+		msgs = nil
+		batch = nil
 	}
 
-	l := make([]uint64, 0)
-	for k, v := range d {
-		if v == 2 {
-			l = append(l, k)
+	for _, index := range indices {
+		item, err := h.getItemInternal(state, index)
+		if err != nil {
+			log.Err(err).Msg("could not get item")
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		if item.Item.Claimed {
+			resp.Claimed = append(resp.Claimed, item.Item.Index)
+			continue
+		}
+
+		msgs = append(msgs, claimMessage(collection, index, item))
+		batch = append(batch, item.Item.Index)
+
+		if len(msgs) == or.PerTransaction {
+			flush()
 		}
 	}
+	flush()
 
-	return l
+	return c.JSON(http.StatusOK, resp)
 }
 
-var ErrNothingToRediscover = errors.New("nothing to rediscover")
+type VerifyRequest struct {
+	ProofCell *cell.Cell  `json:"proof_cell"`
+	Index     string      `json:"index"`
+	Root      *types.Node `json:"root"`
+}
 
-func (h *Handler) rediscoverFromState(c echo.Context, state *state.FullState) error {
-	ip := h.ItemProvider
-	np := h.NodeProvider
-	depth := h.Depth
-	newStates := h.NewStates
-	upr := h.UpdateRecorder
+type VerifyResponse struct {
+	Valid bool               `json:"valid"`
+	Error string             `json:"error,omitempty"`
+	Root  types.Node         `json:"root"`
+	Item  *data.ItemMetadata `json:"item,omitempty"`
+}
+
+func (h *Handler) verify(c echo.Context) error {
+	vr := new(VerifyRequest)
+	if err := c.Bind(vr); err != nil {
+		log.Err(err).Msg("bad verify request")
+		return c.String(http.StatusBadRequest, "bad request")
+	}
+
+	if vr.ProofCell == nil {
+		log.Error().Msg("verify request has no proof cell")
+		return c.String(http.StatusBadRequest, "bad request")
+	}
 
-	prevLastIndex := state.CurrentState.LastIndex
-	newLastIndex, err := ip.Count()
+	index, err := strconv.ParseUint(vr.Index, 10, 64)
 	if err != nil {
-		return err
+		log.Err(err).Msg("bad verify request index")
+		return c.String(http.StatusBadRequest, "bad request")
 	}
-	newLastIndex--
 
-	if newLastIndex == prevLastIndex {
-		return ErrNothingToRediscover
+	root := h.StateHolder.GetFullState().CurrentState.Root
+	if vr.Root != nil {
+		root = *vr.Root
 	}
 
-	newVersion := state.CurrentState.Version + 1
+	resp := &VerifyResponse{
+		Root: root,
+	}
 
-	err = setItemHashes(ip, np, depth, prevLastIndex+1, newLastIndex, newVersion)
+	item, err := proof.Verify(vr.ProofCell, root, index, h.Depth)
 	if err != nil {
-		return err
+		resp.Error = err.Error()
+		return c.JSON(http.StatusOK, resp)
 	}
 
-	setNodesFrom := uint64(1<<depth) + prevLastIndex + 1
+	resp.Valid = true
 
-	err = setNodes(np, depth, setNodesFrom, uint64(1<<depth)+newLastIndex, newVersion)
+	resp.Item, err = data.ParseItemMetadata(item)
 	if err != nil {
-		return err
+		log.Err(err).Msg("could not parse verified item data")
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+type StateResponse struct {
+	Depth     int                `json:"depth"`
+	Capacity  string             `json:"capacity"`
+	LastIndex string             `json:"last_index"`
+	Root      types.Node         `json:"root"`
+	Address   *myaddress.Address `json:"address"`
+}
+
+func (h *Handler) getState(c echo.Context) error {
+	sh := h.StateHolder
+
+	state := sh.GetFullState()
+
+	resp := &StateResponse{
+		Depth:     h.Depth,
+		Root:      state.CurrentState.Root,
+		Capacity:  new(big.Int).Lsh(big.NewInt(1), uint(h.Depth)).String(),
+		LastIndex: strconv.FormatUint(state.CurrentState.LastIndex, 10),
+		Address:   &myaddress.Address{Address: state.CurrentState.Address.Address},
 	}
 
-	root, err := np.GetNode(1, newVersion)
+	return c.JSON(http.StatusOK, resp)
+}
+
+type ClaimStatsResponse struct {
+	Total         string     `json:"total"`
+	Claimed       string     `json:"claimed"`
+	Unclaimed     string     `json:"unclaimed"`
+	LastClaimedAt *time.Time `json:"last_claimed_at"`
+}
+
+func (h *Handler) getClaimStats(c echo.Context) error {
+	state := h.StateHolder.GetFullState()
+
+	total := uint64(0)
+	if state.CurrentState.Version > 0 {
+		total = state.CurrentState.LastIndex + 1
+	}
+
+	stats, err := h.ClaimProvider.GetStats()
 	if err != nil {
-		return err
+		log.Err(err).Msg("could not get claim stats")
+		return c.NoContent(http.StatusInternalServerError)
 	}
 
-	newState := &types.State{
-		LastIndex: newLastIndex,
-		Version:   newVersion,
-		Root:      root,
+	unclaimed := uint64(0)
+	if stats.Claimed < total {
+		unclaimed = total - stats.Claimed
 	}
 
-	nodesToUpd := getNodesToUpdate(setNodesFrom, uint64(1<<(depth+1))-1, depth, 1, 0)
+	resp := &ClaimStatsResponse{
+		Total:         strconv.FormatUint(total, 10),
+		Claimed:       strconv.FormatUint(stats.Claimed, 10),
+		Unclaimed:     strconv.FormatUint(unclaimed, 10),
+		LastClaimedAt: stats.LastClaimedAt,
+	}
 
-	nodesToProv := getNodesToProvide(nodesToUpd)
+	return c.JSON(http.StatusOK, resp)
+}
 
-	updatesMap := map[int]updates.NodeUpdate{}
-	for _, n := range nodesToUpd {
-		nd := 64 - bits.LeadingZeros64(n) - 1
-		node, err := np.GetNode(n, newVersion)
-		if err != nil {
-			if err == provider.ErrNodeNotExist {
-				node = hash.ZeroNodes[depth-nd]
-			} else {
-				return err
-			}
+type RoyaltyResponse struct {
+	Base      uint64             `json:"base"`
+	Factor    uint64             `json:"factor"`
+	Recipient *myaddress.Address `json:"recipient"`
+}
+
+type CollectionResponse struct {
+	Owner           *myaddress.Address `json:"owner"`
+	CollectionMeta  string             `json:"collection_meta"`
+	CommonItemMeta  string             `json:"common_item_meta"`
+	Royalty         RoyaltyResponse    `json:"royalty"`
+	APILink         string             `json:"api_link"`
+	ContentTemplate string             `json:"content_template"`
+	Depth           int                `json:"depth"`
+	Root            types.Node         `json:"root"`
+	Address         *myaddress.Address `json:"address"`
+	StateInit       *cell.Cell         `json:"state_init"`
+}
+
+func (h *Handler) getCollection(c echo.Context) error {
+	coll, err := h.CollectionProvider.GetCollection()
+	if err != nil {
+		if err == provider.ErrCollectionNotExist {
+			return c.String(http.StatusNotFound, "collection has not been created yet")
 		}
+		log.Err(err).Msg("could not get collection")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	resp := &CollectionResponse{
+		Owner:          &myaddress.Address{Address: coll.Owner},
+		CollectionMeta: coll.CollectionMeta,
+		CommonItemMeta: coll.CommonItemMeta,
+		Royalty: RoyaltyResponse{
+			Base:      coll.RoyaltyBase,
+			Factor:    coll.RoyaltyFactor,
+			Recipient: &myaddress.Address{Address: coll.RoyaltyRecipient},
+		},
+		APILink:         coll.APILink,
+		ContentTemplate: coll.ContentTemplate,
+		Depth:           coll.Depth,
+		Root:            coll.Root,
+		Address:         &myaddress.Address{Address: coll.Address},
+		StateInit:       coll.StateInit,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
 
-		updatesMap[nd] = updates.NodeUpdate{
-			Index: n,
-			Node:  &node,
+func (h *Handler) discoverFirst(c echo.Context) (*types.State, error) {
+	ip := h.ItemProvider
+	newStates := h.NewStates
+	upr := h.UpdateRecorder
+
+	itemCount, err := ip.Count()
+	if err != nil {
+		return nil, err
+	}
+
+	tree := merkle.NewTree(h.NodeProvider, h.Depth, 0, 0)
+
+	root, err := tree.AppendFrom(ip, itemCount)
+	if err != nil {
+		if err == merkle.ErrNothingToAppend {
+			return nil, ErrNothingToRediscover
 		}
+		return nil, err
 	}
 
-	prov := map[uint64]*types.Node{}
-	for _, n := range nodesToProv {
-		nd := 64 - bits.LeadingZeros64(n) - 1
-		node, err := np.GetNode(n, newVersion-1)
-		if err != nil {
-			if err == provider.ErrNodeNotExist {
-				node = hash.ZeroNodes[depth-nd]
-			} else {
-				return err
-			}
+	state := &types.State{
+		LastIndex: itemCount - 1,
+		Version:   tree.Version(),
+		Depth:     tree.Depth(),
+		Root:      root,
+	}
+
+	newStates <- state
+
+	var upd updates.Create
+	upd.Type = "create"
+	upd.Root = hex.EncodeToString(state.Root.Hash[:])
+	upd.Depth = state.Depth
+	upd.LastIndex = state.LastIndex
+
+	err = upr.Record(upd, state.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+var ErrNothingToRediscover = errors.New("nothing to rediscover")
+
+func (h *Handler) rediscoverFromState(c echo.Context, state *state.FullState) error {
+	ip := h.ItemProvider
+	newStates := h.NewStates
+	upr := h.UpdateRecorder
+
+	itemCount, err := ip.Count()
+	if err != nil {
+		return err
+	}
+
+	tree := h.tree(state.CurrentState)
+
+	root, err := tree.AppendFrom(ip, itemCount)
+	if err != nil {
+		if err == merkle.ErrNothingToAppend {
+			return ErrNothingToRediscover
 		}
+		return err
+	}
 
-		prov[n] = &node
+	newState := &types.State{
+		LastIndex: itemCount - 1,
+		Version:   tree.Version(),
+		Depth:     tree.Depth(),
+		Root:      root,
 	}
 
-	var upd updates.Update
-	upd.Type = "update"
-	upd.Root = hex.EncodeToString(newState.Root.Hash[:])
-	upd.Updates = updatesMap
-	upd.Hashes = prov
-	upd.NewLastIndex = newState.LastIndex
+	upd, err := tree.UpdateProof(state.CurrentState.Version, newState.Version)
+	if err != nil {
+		return err
+	}
 
 	newStates <- newState
 
@@ -461,7 +790,7 @@ func (h *Handler) rediscover(c echo.Context) error {
 
 	var err error
 	if state.CurrentState.Version == 0 {
-		err = h.discoverFirst(c)
+		_, err = h.discoverFirst(c)
 	} else {
 		err = h.rediscoverFromState(c, state)
 	}
@@ -496,7 +825,269 @@ func (h *Handler) setAddr(c echo.Context) error {
 		return c.String(http.StatusBadRequest, "bad request")
 	}
 
+	coll, err := h.CollectionProvider.GetCollection()
+	if err != nil {
+		if err != provider.ErrCollectionNotExist {
+			log.Err(err).Msg("could not get collection")
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		log.Warn().Msg("no collection is stored, cannot check the submitted address")
+	} else if !myaddress.Equal(parsed, coll.Address) {
+		log.Error().Str("address", parsed.String()).Str("expected", coll.Address.String()).Msg("submitted address does not match the stored collection")
+		return c.String(http.StatusBadRequest, "address does not match the stored collection address "+coll.Address.String())
+	}
+
 	addrs <- parsed
 
 	return c.String(http.StatusOK, "ok")
 }
+
+type DeployRequest struct {
+	Owner            string `json:"owner"`
+	CollectionMeta   string `json:"collection_meta"`
+	CommonItemMeta   string `json:"common_item_meta"`
+	RoyaltyBase      uint64 `json:"royalty_base"`
+	RoyaltyFactor    uint64 `json:"royalty_factor"`
+	RoyaltyRecipient string `json:"royalty_recipient"`
+	APILink          string `json:"api_link"`
+	Amount           string `json:"amount"`
+}
+
+type DeployResponse struct {
+	Address       *myaddress.Address          `json:"address"`
+	Root          types.Node                  `json:"root"`
+	LastIndex     string                      `json:"last_index"`
+	Link          string                      `json:"link"`
+	TonkeeperLink string                      `json:"tonkeeper_link"`
+	TonConnect    *transfer.TonConnectRequest `json:"tonconnect"`
+}
+
+func (dr *DeployRequest) params() (*contract.CollectionParams, error) {
+	if dr.Owner == "" || dr.RoyaltyRecipient == "" || dr.CollectionMeta == "" || dr.APILink == "" {
+		return nil, errors.New("owner, collection_meta, royalty_recipient and api_link are required")
+	}
+
+	owner, err := address.ParseAddr(dr.Owner)
+	if err != nil {
+		return nil, fmt.Errorf("invalid owner: %w", err)
+	}
+
+	royaltyRecipient, err := address.ParseAddr(dr.RoyaltyRecipient)
+	if err != nil {
+		return nil, fmt.Errorf("invalid royalty_recipient: %w", err)
+	}
+
+	p := &contract.CollectionParams{
+		Owner:            owner,
+		CollectionMeta:   dr.CollectionMeta,
+		CommonItemMeta:   dr.CommonItemMeta,
+		RoyaltyBase:      dr.RoyaltyBase,
+		RoyaltyFactor:    dr.RoyaltyFactor,
+		RoyaltyRecipient: royaltyRecipient,
+		APILink:          dr.APILink,
+	}
+
+	return p, p.Validate()
+}
+
+func (h *Handler) deploy(c echo.Context) error {
+	dr := new(DeployRequest)
+	if err := c.Bind(dr); err != nil {
+		log.Err(err).Msg("bad deploy request")
+		return c.String(http.StatusBadRequest, "bad request")
+	}
+
+	if h.StateHolder.GetFullState().CurrentState.Version != 0 {
+		return c.String(http.StatusConflict, "collection is already deployed")
+	}
+
+	params, err := dr.params()
+	if err != nil {
+		log.Err(err).Msg("bad deploy request")
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	amount := uint64(contract.DEPLOY_AMOUNT)
+	if dr.Amount != "" {
+		amount, err = strconv.ParseUint(dr.Amount, 10, 64)
+		if err != nil {
+			log.Err(err).Msg("bad deploy amount")
+			return c.String(http.StatusBadRequest, "bad amount")
+		}
+	}
+
+	state, err := h.discoverFirst(c)
+	if err != nil {
+		log.Err(err).Msg("could not discover the first version")
+		if err == ErrNothingToRediscover {
+			return c.String(http.StatusNotAcceptable, "nothing to deploy")
+		}
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	coll, err := contract.NewCollection(state.Root, state.Depth, params)
+	if err != nil {
+		log.Err(err).Msg("could not build the collection state init")
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	coll.ContentTemplate = h.ContentTemplate
+
+	err = h.CollectionProvider.SetCollection(coll)
+	if err != nil {
+		log.Err(err).Msg("could not save collection")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	h.Addresses <- coll.Address
+
+	msg := &transfer.Message{
+		Destination: coll.Address,
+		Amount:      amount,
+		StateInit:   coll.StateInit,
+	}
+
+	resp := &DeployResponse{
+		Address:       &myaddress.Address{Address: coll.Address},
+		Root:          coll.Root,
+		LastIndex:     strconv.FormatUint(state.LastIndex, 10),
+		Link:          msg.Deeplink(),
+		TonkeeperLink: msg.TonkeeperLink(),
+		TonConnect:    transfer.TonConnect(msg),
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+type UpdateRequest struct {
+	Version int    `param:"version"`
+	Amount  string `query:"amount"`
+}
+
+type UpdateResponse struct {
+	Version       int                         `json:"version"`
+	Status        string                      `json:"status"`
+	Update        json.RawMessage             `json:"update"`
+	Body          *cell.Cell                  `json:"body,omitempty"`
+	StateInit     *cell.Cell                  `json:"state_init,omitempty"`
+	Link          string                      `json:"link,omitempty"`
+	TonkeeperLink string                      `json:"tonkeeper_link,omitempty"`
+	TonConnect    *transfer.TonConnectRequest `json:"tonconnect,omitempty"`
+}
+
+const (
+	UPDATE_STATUS_APPLIED = "applied"
+	UPDATE_STATUS_PENDING = "pending"
+)
+
+func (h *Handler) updateMessage(s *types.State, version int, typ string, raw json.RawMessage) (*transfer.Message, error) {
+	if typ == "create" {
+		coll, err := h.CollectionProvider.GetCollection()
+		if err != nil {
+			if err == provider.ErrCollectionNotExist {
+				return nil, errors.New("collection params are not known, use /admin/deploy")
+			}
+			return nil, err
+		}
+
+		var upd updates.Create
+		err = json.Unmarshal(raw, &upd)
+		if err != nil {
+			return nil, err
+		}
+
+		if hex.EncodeToString(coll.Root.Hash[:]) != upd.Root {
+			return nil, errors.New("saved collection was built for another root, use /admin/deploy")
+		}
+
+		return &transfer.Message{
+			Destination: coll.Address,
+			Amount:      contract.DEPLOY_AMOUNT,
+			StateInit:   coll.StateInit,
+		}, nil
+	}
+
+	if version != s.Version+1 {
+		return nil, fmt.Errorf("version %v is committed, only version %v can be applied", s.Version, s.Version+1)
+	}
+
+	if s.Address == nil {
+		return nil, errors.New("collection address is not known yet")
+	}
+
+	var upd updates.Update
+	err := json.Unmarshal(raw, &upd)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := contract.CheckUpdate(&upd, h.Depth, s.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	return &transfer.Message{
+		Destination: s.Address.Address,
+		Amount:      contract.UPDATE_AMOUNT,
+		Body:        body,
+	}, nil
+}
+
+func (h *Handler) getUpdate(c echo.Context) error {
+	ur := new(UpdateRequest)
+	if err := c.Bind(ur); err != nil {
+		log.Err(err).Msg("bad update request")
+		return c.String(http.StatusBadRequest, "bad request")
+	}
+
+	var raw json.RawMessage
+	err := h.UpdateRecorder.Load(ur.Version, &raw)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return c.String(http.StatusNotFound, "update not found")
+		}
+		log.Err(err).Msg("could not load update")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	var rec updates.Record
+	err = json.Unmarshal(raw, &rec)
+	if err != nil {
+		log.Err(err).Msg("could not parse update")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	s := h.StateHolder.GetFullState().CurrentState
+
+	resp := &UpdateResponse{
+		Version: ur.Version,
+		Status:  UPDATE_STATUS_APPLIED,
+		Update:  raw,
+	}
+
+	if ur.Version <= s.Version {
+		return c.JSON(http.StatusOK, resp)
+	}
+
+	msg, err := h.updateMessage(s, ur.Version, rec.Type, raw)
+	if err != nil {
+		log.Err(err).Int("version", ur.Version).Msg("could not build update message")
+		return c.String(http.StatusConflict, err.Error())
+	}
+
+	if ur.Amount != "" {
+		msg.Amount, err = strconv.ParseUint(ur.Amount, 10, 64)
+		if err != nil {
+			log.Err(err).Msg("bad update amount")
+			return c.String(http.StatusBadRequest, "bad amount")
+		}
+	}
+
+	resp.Status = UPDATE_STATUS_PENDING
+	resp.Body = msg.Body
+	resp.StateInit = msg.StateInit
+	resp.Link = msg.Deeplink()
+	resp.TonkeeperLink = msg.TonkeeperLink()
+	resp.TonConnect = transfer.TonConnect(msg)
+
+	return c.JSON(http.StatusOK, resp)
+}
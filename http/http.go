@@ -11,7 +11,21 @@ func (h *Handler) RegisterHandlers(e *echo.Echo) {
 
 	v1.GET("/items", h.getItems)
 	v1.GET("/items/:index", h.getItem)
+	v1.GET("/items/:index/claim", h.getClaim)
+	v1.GET("/items/:index/address", h.getItemAddress)
+	v1.GET("/owners/:address/items", h.getOwnerItems)
+	v1.GET("/owners/:address/claim", h.getOwnerClaim)
 	v1.GET("/state", h.getState)
+	v1.GET("/collection", h.getCollection)
+	v1.GET("/claims/stats", h.getClaimStats)
+	v1.POST("/verify", h.verify)
+
+	if h.Metadata != nil {
+		meta := e.Group("/meta")
+
+		meta.GET("/collection.json", h.getCollectionMetadata)
+		meta.GET("/items/*", h.getItemMetadata)
+	}
 
 	admin := e.Group("/admin")
 
@@ -21,4 +35,6 @@ func (h *Handler) RegisterHandlers(e *echo.Echo) {
 
 	admin.GET("/rediscover", h.rediscover)
 	admin.GET("/setaddr/:addr", h.setAddr)
+	admin.POST("/deploy", h.deploy)
+	admin.GET("/updates/:version", h.getUpdate)
 }
@@ -0,0 +1,49 @@
+package http
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+	"github.com/ton-community/compressed-nft-api/data"
+)
+
+func (h *Handler) getCollectionMetadata(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.Metadata.Collection)
+}
+
+// getItemMetadata serves the metadata of the item whose individual content is
+// the requested path; items with content of their own are not served
+func (h *Handler) getItemMetadata(c echo.Context) error {
+	content := c.Param("*")
+
+	index, ok := h.ContentMatcher.Match(content)
+	if !ok {
+		return c.String(http.StatusNotFound, "not found")
+	}
+
+	state := h.StateHolder.GetFullState()
+
+	if state.CurrentState.Version == 0 || index > state.CurrentState.LastIndex {
+		return c.String(http.StatusNotFound, "not found")
+	}
+
+	item, err := h.ItemProvider.GetItem(index)
+	if err != nil {
+		log.Err(err).Msg("could not get item")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	if !bytes.Equal(item.IndividualContent.Hash(), data.ContentCell(h.ContentTemplate, index).Hash()) {
+		return c.String(http.StatusNotFound, "not found")
+	}
+
+	attrs, err := h.AttributeProvider.GetAttributes(index)
+	if err != nil {
+		log.Err(err).Msg("could not get item attributes")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, h.Metadata.RenderItem(index, attrs))
+}
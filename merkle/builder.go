@@ -0,0 +1,132 @@
+package merkle
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ton-community/compressed-nft-api/hash"
+	"github.com/ton-community/compressed-nft-api/provider"
+	"github.com/ton-community/compressed-nft-api/types"
+)
+
+var ErrTreeFull = errors.New("tree is full")
+
+// RootBuilder folds leaves added in index order into a tree root while keeping
+// only one pending node per level in memory
+type RootBuilder struct {
+	depth   int
+	count   uint64
+	pending []*types.Node
+}
+
+func NewRootBuilder(depth int) *RootBuilder {
+	return &RootBuilder{
+		depth:   depth,
+		pending: make([]*types.Node, depth+1),
+	}
+}
+
+func (rb *RootBuilder) Count() uint64 {
+	return rb.count
+}
+
+func (rb *RootBuilder) Add(leaf types.Node) error {
+	if rb.pending[rb.depth] != nil {
+		return ErrTreeFull
+	}
+
+	node := leaf
+	level := 0
+	for rb.pending[level] != nil {
+		node = hash.Nodes(*rb.pending[level], node)
+		rb.pending[level] = nil
+		level++
+	}
+	rb.pending[level] = &node
+
+	rb.count++
+
+	return nil
+}
+
+func (rb *RootBuilder) Root() types.Node {
+	if rb.pending[rb.depth] != nil {
+		return *rb.pending[rb.depth]
+	}
+
+	var carry *types.Node
+	for level := 0; level < rb.depth; level++ {
+		var node types.Node
+		if rb.pending[level] != nil {
+			right := hash.ZeroNode(level)
+			if carry != nil {
+				right = *carry
+			}
+			node = hash.Nodes(*rb.pending[level], right)
+		} else if carry != nil {
+			node = hash.Nodes(*carry, hash.ZeroNode(level))
+		} else {
+			continue
+		}
+		carry = &node
+	}
+
+	if carry == nil {
+		return hash.ZeroNode(rb.depth)
+	}
+
+	return *carry
+}
+
+const ROOT_BATCH_SIZE = 10000
+
+// ComputeRoot recomputes the root of a tree holding the first count items
+// straight from the item provider
+func ComputeRoot(ip provider.ItemProvider, depth int, count uint64) (types.Node, error) {
+	roots, err := ComputeRoots(ip, depth, []uint64{count})
+	if err != nil {
+		return types.Node{}, err
+	}
+
+	return roots[0], nil
+}
+
+// ComputeRoots does the same as ComputeRoot for several ascending item counts
+// in a single pass over the items
+func ComputeRoots(ip provider.ItemProvider, depth int, counts []uint64) ([]types.Node, error) {
+	rb := NewRootBuilder(depth)
+	roots := make([]types.Node, 0, len(counts))
+
+	for _, count := range counts {
+		for from := rb.Count(); from < count; from += ROOT_BATCH_SIZE {
+			batch := count - from
+			if batch > ROOT_BATCH_SIZE {
+				batch = ROOT_BATCH_SIZE
+			}
+
+			items, err := ip.GetItems(from, batch)
+			if err != nil {
+				return nil, err
+			}
+
+			for i, item := range items {
+				if item == nil {
+					return nil, fmt.Errorf("item %v does not exist", from+uint64(i))
+				}
+
+				err = rb.Add(item.ToNode())
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if rb.Count() != count {
+			return nil, fmt.Errorf("item counts must be ascending, got %v after %v", count, rb.Count())
+		}
+
+		roots = append(roots, rb.Root())
+	}
+
+	return roots, nil
+}
@@ -0,0 +1,110 @@
+package merkle
+
+import (
+	"github.com/ton-community/compressed-nft-api/hash"
+	"github.com/ton-community/compressed-nft-api/provider"
+)
+
+func SetItemHashes(ip provider.ItemProvider, np provider.NodeProvider, depth int, from, to uint64, version int) error {
+	nodeIndexOffset := uint64(1) << depth
+	for i := from; i <= to; i++ {
+		item, err := ip.GetItem(i)
+		if err != nil {
+			return err
+		}
+
+		err = np.SetNode(i+nodeIndexOffset, version, item.ToNode())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func SetNodes(np provider.NodeProvider, depth int, nodeFrom, nodeTo uint64, version int) error {
+	for d := depth - 1; d >= 0; d-- {
+		nodeFrom >>= 1
+		nodeTo >>= 1
+
+		for node := nodeFrom; node <= nodeTo; node++ {
+			nl, err := np.GetNode(2*node, version)
+			if err != nil {
+				if err == provider.ErrNodeNotExist {
+					nl = hash.ZeroNode(depth - d - 1)
+				} else {
+					return err
+				}
+			}
+
+			nr, err := np.GetNode(2*node+1, version)
+			if err != nil {
+				if err == provider.ErrNodeNotExist {
+					nr = hash.ZeroNode(depth - d - 1)
+				} else {
+					return err
+				}
+			}
+
+			err = np.SetNode(node, version, hash.Nodes(nl, nr))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func getNodesToUpdate(start, end uint64, depth int, cn uint64, cd int) []uint64 {
+	cns := cn << (depth - cd)
+	cne := cns + (1 << (depth - cd)) - 1
+
+	if start > cne || end < cns {
+		return nil
+	}
+
+	if cns >= start && cne <= end {
+		return []uint64{cn}
+	}
+
+	left := getNodesToUpdate(start, end, depth, 2*cn, cd+1)
+	right := getNodesToUpdate(start, end, depth, 2*cn+1, cd+1)
+
+	return append(left, right...)
+}
+
+func getNodesToProvide(nodes []uint64) []uint64 {
+	d := map[uint64]byte{}
+	for _, n := range nodes {
+		d[n] = 1
+		if n == 1 {
+			continue
+		}
+		nn := n ^ 1
+		if _, ok := d[nn]; !ok {
+			d[nn] = 2
+		}
+	}
+
+	for _, n := range nodes {
+		n >>= 1
+		for n > 1 {
+			d[n] = 3
+			nn := n ^ 1
+			if _, ok := d[nn]; !ok {
+				d[nn] = 2
+			}
+			n >>= 1
+		}
+	}
+
+	l := make([]uint64, 0)
+	for k, v := range d {
+		if v == 2 {
+			l = append(l, k)
+		}
+	}
+
+	return l
+}
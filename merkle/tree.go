@@ -0,0 +1,247 @@
+package merkle
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/bits"
+
+	"github.com/ton-community/compressed-nft-api/data"
+	"github.com/ton-community/compressed-nft-api/hash"
+	"github.com/ton-community/compressed-nft-api/provider"
+	"github.com/ton-community/compressed-nft-api/types"
+	"github.com/ton-community/compressed-nft-api/updates"
+)
+
+var ErrNothingToAppend = errors.New("nothing to append")
+
+// Tree is a versioned, append-only Merkle tree stored in a NodeProvider. Every
+// Append creates a new version on top of the latest one, and all older
+// versions stay readable.
+type Tree struct {
+	np      provider.NodeProvider
+	depth   int
+	version int
+	count   uint64
+}
+
+// NewTree opens a tree whose latest version holds count items. An empty tree
+// has version 0.
+func NewTree(np provider.NodeProvider, depth int, version int, count uint64) *Tree {
+	return &Tree{
+		np:      np,
+		depth:   depth,
+		version: version,
+		count:   count,
+	}
+}
+
+func (t *Tree) Depth() int {
+	return t.depth
+}
+
+func (t *Tree) Version() int {
+	return t.version
+}
+
+func (t *Tree) Count() uint64 {
+	return t.count
+}
+
+func (t *Tree) node(index uint64, version int) (types.Node, error) {
+	node, err := t.np.GetNode(index, version)
+	if err == provider.ErrNodeNotExist {
+		return hash.ZeroNode(t.depth - (bits.Len64(index) - 1)), nil
+	}
+
+	return node, err
+}
+
+func (t *Tree) begin(n uint64) (uint64, error) {
+	if n == 0 {
+		return 0, ErrNothingToAppend
+	}
+
+	if n > (uint64(1)<<t.depth)-t.count {
+		return 0, ErrTreeFull
+	}
+
+	return uint64(1)<<t.depth + t.count, nil
+}
+
+func (t *Tree) finish(n uint64) (types.Node, error) {
+	offset := uint64(1) << t.depth
+
+	err := SetNodes(t.np, t.depth, offset+t.count, offset+t.count+n-1, t.version+1)
+	if err != nil {
+		return types.Node{}, err
+	}
+
+	t.version++
+	t.count += n
+
+	return t.Root(t.version)
+}
+
+// Append adds the items after the existing ones as a new version and returns
+// its root
+func (t *Tree) Append(items []*data.ItemMetadata) (types.Node, error) {
+	first, err := t.begin(uint64(len(items)))
+	if err != nil {
+		return types.Node{}, err
+	}
+
+	for i, item := range items {
+		err = t.np.SetNode(first+uint64(i), t.version+1, item.ToNode())
+		if err != nil {
+			return types.Node{}, err
+		}
+	}
+
+	return t.finish(uint64(len(items)))
+}
+
+// AppendFrom does the same as Append for the items that the item provider has
+// past the current count, up to newCount
+func (t *Tree) AppendFrom(ip provider.ItemProvider, newCount uint64) (types.Node, error) {
+	if newCount < t.count {
+		return types.Node{}, fmt.Errorf("tree already has %v items", t.count)
+	}
+
+	_, err := t.begin(newCount - t.count)
+	if err != nil {
+		return types.Node{}, err
+	}
+
+	err = SetItemHashes(ip, t.np, t.depth, t.count, newCount-1, t.version+1)
+	if err != nil {
+		return types.Node{}, err
+	}
+
+	return t.finish(newCount - t.count)
+}
+
+// Root returns the root of the given version; version 0 is the empty tree
+func (t *Tree) Root(version int) (types.Node, error) {
+	if version < 0 || version > t.version {
+		return types.Node{}, fmt.Errorf("tree has no version %v, the latest one is %v", version, t.version)
+	}
+
+	if version == 0 {
+		return hash.ZeroNode(t.depth), nil
+	}
+
+	node, err := t.np.GetNode(1, version)
+	if err == provider.ErrNodeNotExist {
+		return types.Node{}, fmt.Errorf("root of version %v is missing", version)
+	}
+
+	return node, err
+}
+
+// Proof returns the sibling hashes of the item at index in the given version,
+// ordered from the leaf level up
+func (t *Tree) Proof(index uint64, version int) ([]types.Node, error) {
+	siblings := make([]types.Node, 0, t.depth)
+	nodeIndex := uint64(1)<<t.depth + index
+	for i := 0; i < t.depth; i++ {
+		node, err := t.node(nodeIndex^1, version)
+		if err != nil {
+			return nil, err
+		}
+
+		siblings = append(siblings, node)
+
+		nodeIndex >>= 1
+	}
+
+	return siblings, nil
+}
+
+// CountAt returns the number of items in the given version, including a
+// pending one past the version the tree was opened with
+func (t *Tree) CountAt(version int) (uint64, error) {
+	if version == t.version {
+		return t.count, nil
+	}
+
+	offset := uint64(1) << t.depth
+	lo, hi := uint64(0), offset
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		_, err := t.np.GetNode(offset+mid, version)
+		if err == provider.ErrNodeNotExist {
+			hi = mid
+		} else if err != nil {
+			return 0, err
+		} else {
+			lo = mid + 1
+		}
+	}
+
+	return lo, nil
+}
+
+// UpdateProof builds the update that moves a collection from the root of
+// fromVersion to the root of toVersion; version 0 is the empty tree
+func (t *Tree) UpdateProof(fromVersion, toVersion int) (*updates.Update, error) {
+	if fromVersion < 0 || toVersion <= fromVersion {
+		return nil, fmt.Errorf("cannot build an update from version %v to version %v", fromVersion, toVersion)
+	}
+
+	fromCount, err := t.CountAt(fromVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	toCount, err := t.CountAt(toVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	if toCount <= fromCount {
+		return nil, ErrNothingToAppend
+	}
+
+	root, err := t.Root(toVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := uint64(1) << t.depth
+
+	nodesToUpd := getNodesToUpdate(offset+fromCount, 2*offset-1, t.depth, 1, 0)
+
+	nodesToProv := getNodesToProvide(nodesToUpd)
+
+	updatesMap := map[int]updates.NodeUpdate{}
+	for _, n := range nodesToUpd {
+		node, err := t.node(n, toVersion)
+		if err != nil {
+			return nil, err
+		}
+
+		updatesMap[bits.Len64(n)-1] = updates.NodeUpdate{
+			Index: n,
+			Node:  &node,
+		}
+	}
+
+	prov := map[uint64]*types.Node{}
+	for _, n := range nodesToProv {
+		node, err := t.node(n, fromVersion)
+		if err != nil {
+			return nil, err
+		}
+
+		prov[n] = &node
+	}
+
+	return &updates.Update{
+		Type:         "update",
+		Root:         hex.EncodeToString(root.Hash[:]),
+		Updates:      updatesMap,
+		Hashes:       prov,
+		NewLastIndex: toCount - 1,
+	}, nil
+}
@@ -0,0 +1,429 @@
+package merkle
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/bits"
+	"testing"
+
+	myaddr "github.com/ton-community/compressed-nft-api/address"
+	"github.com/ton-community/compressed-nft-api/data"
+	"github.com/ton-community/compressed-nft-api/hash"
+	"github.com/ton-community/compressed-nft-api/proof"
+	"github.com/ton-community/compressed-nft-api/provider"
+	"github.com/ton-community/compressed-nft-api/types"
+	"github.com/ton-community/compressed-nft-api/updates"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+const TEST_DEPTH = 4
+
+// batches fill a tree of TEST_DEPTH, so the last version is full
+var batches = []uint64{3, 1, 5, 7}
+
+type memNodes map[uint64]map[int]types.Node
+
+// GetNode returns the node from the latest version up to the given one, like
+// pg.NodeProvider
+func (m memNodes) GetNode(index uint64, version int) (types.Node, error) {
+	best := -1
+	for v := range m[index] {
+		if v <= version && v > best {
+			best = v
+		}
+	}
+	if best < 0 {
+		return types.Node{}, provider.ErrNodeNotExist
+	}
+
+	return m[index][best], nil
+}
+
+func (m memNodes) SetNode(index uint64, version int, node types.Node) error {
+	if m[index] == nil {
+		m[index] = map[int]types.Node{}
+	}
+	m[index][version] = node
+
+	return nil
+}
+
+type memItems []*data.ItemMetadata
+
+func (m memItems) GetItem(index uint64) (*data.ItemMetadata, error) {
+	if index >= uint64(len(m)) {
+		return nil, errors.New("no such item")
+	}
+
+	return m[index], nil
+}
+
+func (m memItems) GetItems(from, count uint64) ([]*data.ItemMetadata, error) {
+	items := make([]*data.ItemMetadata, 0, count)
+	for i := from; i < from+count; i++ {
+		item, _ := m.GetItem(i)
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+func (m memItems) Count() (uint64, error) {
+	return uint64(len(m)), nil
+}
+
+func (m memItems) GetOwnerItems(owner *address.Address, from, to, count uint64) ([]uint64, error) {
+	return nil, nil
+}
+
+func testItems(n int) memItems {
+	items := make(memItems, 0, n)
+	for i := 0; i < n; i++ {
+		b := make([]byte, 32)
+		b[0] = byte(i + 1)
+		items = append(items, &data.ItemMetadata{
+			Owner:             &myaddr.Address{Address: address.NewAddress(0, 0, b)},
+			IndividualContent: cell.BeginCell().MustStoreStringSnake(fmt.Sprintf("%v.json", i)).EndCell(),
+		})
+	}
+
+	return items
+}
+
+// testTree appends batches one version at a time and returns the tree, all
+// items and the item count of every version, counts[0] being version 0
+func testTree(t *testing.T) (*Tree, memItems, []uint64) {
+	total := uint64(0)
+	for _, n := range batches {
+		total += n
+	}
+	items := testItems(int(total))
+
+	tree := NewTree(memNodes{}, TEST_DEPTH, 0, 0)
+	counts := []uint64{0}
+	for _, n := range batches {
+		count := tree.Count()
+		_, err := tree.Append(items[count : count+n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts = append(counts, tree.Count())
+	}
+
+	return tree, items, counts
+}
+
+func TestAppend(t *testing.T) {
+	total := uint64(0)
+	for _, n := range batches {
+		total += n
+	}
+	items := testItems(int(total))
+
+	tree := NewTree(memNodes{}, TEST_DEPTH, 0, 0)
+	roots := []types.Node{}
+	for i, n := range batches {
+		count := tree.Count()
+
+		root, err := tree.Append(items[count : count+n])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if tree.Version() != i+1 || tree.Count() != count+n {
+			t.Fatalf("after batch %v: version %v, count %v", i, tree.Version(), tree.Count())
+		}
+
+		want, err := ComputeRoot(items, TEST_DEPTH, count+n)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if root != want {
+			t.Fatalf("version %v: root %v, want %v", i+1, hex.EncodeToString(root.Hash[:]), hex.EncodeToString(want.Hash[:]))
+		}
+
+		roots = append(roots, root)
+	}
+
+	// older versions must stay readable after later appends
+	for i, want := range roots {
+		root, err := tree.Root(i + 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if root != want {
+			t.Fatalf("version %v changed after later appends", i+1)
+		}
+	}
+
+	_, err := tree.Append(nil)
+	if err != ErrNothingToAppend {
+		t.Fatalf("empty append: got %v, want %v", err, ErrNothingToAppend)
+	}
+
+	_, err = tree.Append(testItems(1))
+	if err != ErrTreeFull {
+		t.Fatalf("append to a full tree: got %v, want %v", err, ErrTreeFull)
+	}
+}
+
+func TestAppendFrom(t *testing.T) {
+	want, items, counts := testTree(t)
+
+	tree := NewTree(memNodes{}, TEST_DEPTH, 0, 0)
+	for v := 1; v < len(counts); v++ {
+		root, err := tree.AppendFrom(items, counts[v])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		wantRoot, err := want.Root(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if root != wantRoot {
+			t.Fatalf("version %v: AppendFrom and Append disagree", v)
+		}
+	}
+
+	_, err := tree.AppendFrom(items, counts[1])
+	if err == nil {
+		t.Fatal("AppendFrom accepted a count below the current one")
+	}
+}
+
+func TestProof(t *testing.T) {
+	tree, items, counts := testTree(t)
+
+	tests := []struct {
+		name    string
+		index   uint64
+		version int
+		valid   bool
+	}{
+		{"first of version 1", 0, 1, true},
+		{"last of version 1", counts[1] - 1, 1, true},
+		{"first of version 3", 0, 3, true},
+		{"middle of version 3", counts[3] / 2, 3, true},
+		{"last of version 3", counts[3] - 1, 3, true},
+		{"first of full version", 0, 4, true},
+		{"middle of full version", counts[4] / 2, 4, true},
+		{"last of full version", counts[4] - 1, 4, true},
+		{"past the count of version 2", counts[2], 2, false},
+		{"past the count of version 3", counts[3] + 1, 3, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			siblings, err := tree.Proof(tt.index, tt.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(siblings) != TEST_DEPTH {
+				t.Fatalf("got %v siblings, want %v", len(siblings), TEST_DEPTH)
+			}
+
+			root, err := tree.Root(tt.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			item := items[tt.index].ToCell()
+
+			got, err := proof.Verify(proof.Build(item, siblings), root, tt.index, TEST_DEPTH)
+			if !tt.valid {
+				if err != proof.ErrRootMismatch {
+					t.Fatalf("got %v, want %v", err, proof.ErrRootMismatch)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !cellsEqual(got, item) {
+				t.Fatal("proof proves a different item")
+			}
+		})
+	}
+}
+
+func cellsEqual(a, b *cell.Cell) bool {
+	return hex.EncodeToString(a.Hash()) == hex.EncodeToString(b.Hash())
+}
+
+func TestUpdateProof(t *testing.T) {
+	tree, _, counts := testTree(t)
+
+	tests := []struct {
+		name    string
+		from    int
+		to      int
+		wantErr bool
+	}{
+		{"next version", 1, 2, false},
+		{"several versions", 1, 4, false},
+		{"across a power of two", 2, 3, false},
+		{"to the full tree", 3, 4, false},
+		{"from version 0", 0, 1, false},
+		{"from version 0 to the full tree", 0, 4, false},
+		{"unchanged range", 2, 2, true},
+		{"backwards", 3, 2, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upd, err := tree.UpdateProof(tt.from, tt.to)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fromRoot, err := tree.Root(tt.from)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			toRoot, err := tree.Root(tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if upd.NewLastIndex != counts[tt.to]-1 {
+				t.Fatalf("new last index %v, want %v", upd.NewLastIndex, counts[tt.to]-1)
+			}
+
+			if upd.Root != hex.EncodeToString(toRoot.Hash[:]) {
+				t.Fatalf("update root %v, want %v", upd.Root, hex.EncodeToString(toRoot.Hash[:]))
+			}
+
+			oldRoot, newRoot, err := applyUpdate(upd, TEST_DEPTH)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.from == 0 && fromRoot != hash.ZeroNode(TEST_DEPTH) {
+				t.Fatal("version 0 is not the empty tree")
+			}
+
+			if oldRoot != fromRoot {
+				t.Fatal("update does not start from the root of the from version")
+			}
+
+			if newRoot != toRoot {
+				t.Fatal("applying the update does not give the root of the to version")
+			}
+		})
+	}
+}
+
+// applyUpdate computes the roots before and after an update the way the
+// collection does: updated nodes are empty before and take their new hash
+// after, provided nodes keep their hash, and together they must cover every
+// leaf exactly once
+func applyUpdate(upd *updates.Update, depth int) (types.Node, types.Node, error) {
+	updated := map[uint64]types.Node{}
+	for level, u := range upd.Updates {
+		if bits.Len64(u.Index)-1 != level {
+			return types.Node{}, types.Node{}, fmt.Errorf("node %v is listed at level %v", u.Index, level)
+		}
+		updated[u.Index] = *u.Node
+	}
+
+	used := 0
+	var walk func(index uint64, height int) (types.Node, types.Node, error)
+	walk = func(index uint64, height int) (types.Node, types.Node, error) {
+		if node, ok := updated[index]; ok {
+			used++
+			return hash.ZeroNode(height), node, nil
+		}
+
+		if node, ok := upd.Hashes[index]; ok {
+			used++
+			return *node, *node, nil
+		}
+
+		if height == 0 {
+			return types.Node{}, types.Node{}, fmt.Errorf("no node covers leaf %v", index)
+		}
+
+		lo, ln, err := walk(2*index, height-1)
+		if err != nil {
+			return types.Node{}, types.Node{}, err
+		}
+
+		ro, rn, err := walk(2*index+1, height-1)
+		if err != nil {
+			return types.Node{}, types.Node{}, err
+		}
+
+		return hash.Nodes(lo, ro), hash.Nodes(ln, rn), nil
+	}
+
+	oldRoot, newRoot, err := walk(1, depth)
+	if err != nil {
+		return types.Node{}, types.Node{}, err
+	}
+
+	if used != len(updated)+len(upd.Hashes) {
+		return types.Node{}, types.Node{}, fmt.Errorf("%v nodes are outside of the tree or covered by other nodes", len(updated)+len(upd.Hashes)-used)
+	}
+
+	return oldRoot, newRoot, nil
+}
+
+func TestRoot(t *testing.T) {
+	tree, _, _ := testTree(t)
+
+	root, err := tree.Root(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != hash.ZeroNode(TEST_DEPTH) {
+		t.Fatal("version 0 is not the empty tree")
+	}
+
+	_, err = tree.Root(tree.Version() + 1)
+	if err == nil {
+		t.Fatal("Root returned a root for a version past the latest one")
+	}
+
+	np := memNodes{}
+	_, err = NewTree(np, TEST_DEPTH, 1, 1).Root(1)
+	if err == nil {
+		t.Fatal("Root returned a root that is not stored")
+	}
+}
+
+func TestCountAt(t *testing.T) {
+	full, _, counts := testTree(t)
+
+	// reopen the tree at every version so that CountAt has to search for
+	// the other ones instead of using the count it was opened with
+	for latest := 1; latest < len(counts); latest++ {
+		tree := NewTree(full.np, TEST_DEPTH, latest, counts[latest])
+
+		for v := 0; v <= latest; v++ {
+			count, err := tree.CountAt(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if count != counts[v] {
+				t.Fatalf("opened at version %v: count at version %v is %v, want %v", latest, v, count, counts[v])
+			}
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package metadata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ton-community/compressed-nft-api/data"
+	"github.com/ton-community/compressed-nft-api/types"
+	"gopkg.in/yaml.v3"
+)
+
+// Template holds the TEP-64 collection metadata and the metadata common to
+// all items. {index} placeholders in the string values of Item are rendered
+// the same way as the content template.
+type Template struct {
+	Collection map[string]any `json:"collection" yaml:"collection"`
+	Item       map[string]any `json:"item" yaml:"item"`
+}
+
+func LoadTemplate(p string) (*Template, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var t Template
+	if strings.EqualFold(filepath.Ext(p), ".json") {
+		err = json.Unmarshal(b, &t)
+	} else {
+		err = yaml.Unmarshal(b, &t)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %v: %w", p, err)
+	}
+
+	if t.Collection == nil || t.Item == nil {
+		return nil, errors.New("metadata template must have collection and item sections")
+	}
+
+	return &t, nil
+}
+
+func render(v any, index uint64) any {
+	switch v := v.(type) {
+	case string:
+		return data.RenderContent(v, index)
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			m[k] = render(e, index)
+		}
+		return m
+	case []any:
+		l := make([]any, 0, len(v))
+		for _, e := range v {
+			l = append(l, render(e, index))
+		}
+		return l
+	default:
+		return v
+	}
+}
+
+// RenderItem renders the metadata of an item; attrs are appended to the attributes
+// of the template
+func (t *Template) RenderItem(index uint64, attrs []types.Attribute) map[string]any {
+	m := render(t.Item, index).(map[string]any)
+
+	if len(attrs) == 0 {
+		return m
+	}
+
+	all, _ := m["attributes"].([]any)
+	for _, a := range attrs {
+		all = append(all, a)
+	}
+	m["attributes"] = all
+
+	return m
+}
@@ -0,0 +1 @@
+DROP TABLE collection;
\ No newline at end of file
@@ -0,0 +1,14 @@
+CREATE TABLE collection (
+    id integer NOT NULL PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+    owner character(48) NOT NULL,
+    collection_meta text NOT NULL,
+    common_item_meta text NOT NULL,
+    royalty_base integer NOT NULL,
+    royalty_factor integer NOT NULL,
+    royalty_recipient character(48) NOT NULL,
+    api_link text NOT NULL,
+    depth integer NOT NULL,
+    root bytea NOT NULL,
+    address character(48) NOT NULL,
+    state_init bytea NOT NULL
+);
\ No newline at end of file
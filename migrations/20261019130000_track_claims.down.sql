@@ -0,0 +1,5 @@
+DROP TABLE claim_cursor;
+
+ALTER TABLE items DROP COLUMN claimed_at;
+
+ALTER TABLE items DROP COLUMN claimed;
\ No newline at end of file
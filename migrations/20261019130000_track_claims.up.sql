@@ -0,0 +1,8 @@
+ALTER TABLE items ADD COLUMN claimed boolean NOT NULL DEFAULT false;
+
+ALTER TABLE items ADD COLUMN claimed_at timestamp with time zone;
+
+CREATE TABLE claim_cursor (
+    id integer NOT NULL PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+    lt bigint NOT NULL
+);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP INDEX items_owner_idx;
\ No newline at end of file
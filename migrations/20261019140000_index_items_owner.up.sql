@@ -0,0 +1 @@
+CREATE INDEX items_owner_idx ON items (owner);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE items DROP COLUMN content;
\ No newline at end of file
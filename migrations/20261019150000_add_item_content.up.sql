@@ -0,0 +1 @@
+ALTER TABLE items ADD COLUMN content bytea;
\ No newline at end of file
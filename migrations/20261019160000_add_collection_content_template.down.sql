@@ -0,0 +1 @@
+ALTER TABLE collection DROP COLUMN content_template;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE collection ADD COLUMN content_template text NOT NULL DEFAULT '{index}.json';
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE items DROP COLUMN attributes;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE items ADD COLUMN attributes jsonb;
\ No newline at end of file
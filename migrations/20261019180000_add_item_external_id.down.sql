@@ -0,0 +1,3 @@
+DROP INDEX items_external_id_idx;
+
+ALTER TABLE items DROP COLUMN external_id;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE items ADD COLUMN external_id text;
+
+CREATE UNIQUE INDEX items_external_id_idx ON items (external_id);
\ No newline at end of file
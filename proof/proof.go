@@ -0,0 +1,104 @@
+package proof
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ton-community/compressed-nft-api/hash"
+	"github.com/ton-community/compressed-nft-api/types"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+var ErrRootMismatch = errors.New("proof does not lead to the expected root")
+
+// Build assembles a proof cell from the item data cell and the sibling hashes
+// ordered from the leaf level up
+func Build(item *cell.Cell, siblings []types.Node) *cell.Cell {
+	tree := cell.BeginCell().EndCell()
+	for i := len(siblings) - 1; i >= 0; i-- {
+		tree = cell.BeginCell().MustStoreSlice(siblings[i].Hash[:], types.NODE_LENGTH*8).MustStoreRef(tree).EndCell()
+	}
+
+	return cell.BeginCell().MustStoreRef(item).MustStoreRef(tree).EndCell()
+}
+
+// Parse splits a proof cell as served by the API into the item data cell and
+// the sibling hashes ordered from the leaf level up
+func Parse(proofCell *cell.Cell) (*cell.Cell, []types.Node, error) {
+	s := proofCell.BeginParse()
+
+	itemRef, err := s.LoadRef()
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not load item data: %w", err)
+	}
+
+	item, err := itemRef.ToCell()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tree, err := s.LoadRef()
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not load sibling chain: %w", err)
+	}
+
+	siblings := make([]types.Node, 0)
+	for tree.BitsLeft() > 0 || tree.RefsNum() > 0 {
+		if len(siblings) == types.MAX_DEPTH {
+			return nil, nil, errors.New("sibling chain is too long")
+		}
+
+		b, err := tree.LoadSlice(types.NODE_LENGTH * 8)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not load sibling %v: %w", len(siblings), err)
+		}
+
+		siblings = append(siblings, types.NewNode(b))
+
+		tree, err = tree.LoadRef()
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not load sibling %v: %w", len(siblings), err)
+		}
+	}
+
+	return item, siblings, nil
+}
+
+// Root recomputes the root that the item and siblings lead to if the item is
+// placed at the given index
+func Root(item *cell.Cell, siblings []types.Node, index uint64) types.Node {
+	node := types.NewNode(item.Hash())
+	for _, sibling := range siblings {
+		if index&1 == 0 {
+			node = hash.Nodes(node, sibling)
+		} else {
+			node = hash.Nodes(sibling, node)
+		}
+		index >>= 1
+	}
+
+	return node
+}
+
+// Verify checks that the proof cell proves the item at the given index of a
+// tree of the given depth with the given root and returns the item data cell
+func Verify(proofCell *cell.Cell, root types.Node, index uint64, depth int) (*cell.Cell, error) {
+	item, siblings, err := Parse(proofCell)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(siblings) != depth {
+		return nil, fmt.Errorf("proof has %v levels, but tree depth is %v", len(siblings), depth)
+	}
+
+	if depth < 64 && index>>depth != 0 {
+		return nil, fmt.Errorf("index %v does not fit in a tree of depth %v", index, depth)
+	}
+
+	if Root(item, siblings, index) != root {
+		return nil, ErrRootMismatch
+	}
+
+	return item, nil
+}
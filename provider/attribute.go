@@ -0,0 +1,7 @@
+package provider
+
+import "github.com/ton-community/compressed-nft-api/types"
+
+type AttributeProvider interface {
+	GetAttributes(index uint64) ([]types.Attribute, error)
+}
@@ -0,0 +1,18 @@
+package provider
+
+import (
+	"time"
+
+	"github.com/ton-community/compressed-nft-api/types"
+)
+
+type ClaimProvider interface {
+	// GetClaims returns the claim times of the claimed items in the range
+	GetClaims(from uint64, count uint64) (map[uint64]time.Time, error)
+	SetClaimed(index uint64, at time.Time) error
+	GetStats() (*types.ClaimStats, error)
+
+	// the logical time of the last collection transaction that was scanned
+	GetCursor() (uint64, error)
+	SetCursor(lt uint64) error
+}
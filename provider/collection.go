@@ -0,0 +1,14 @@
+package provider
+
+import (
+	"errors"
+
+	"github.com/ton-community/compressed-nft-api/types"
+)
+
+type CollectionProvider interface {
+	GetCollection() (*types.Collection, error)
+	SetCollection(c *types.Collection) error
+}
+
+var ErrCollectionNotExist = errors.New("collection does not exist")
@@ -1,9 +1,15 @@
 package provider
 
-import "github.com/ton-community/compressed-nft-api/data"
+import (
+	"github.com/ton-community/compressed-nft-api/data"
+	"github.com/xssnick/tonutils-go/address"
+)
 
 type ItemProvider interface {
 	GetItem(index uint64) (*data.ItemMetadata, error)
 	GetItems(from uint64, count uint64) ([]*data.ItemMetadata, error)
 	Count() (uint64, error)
+	// GetOwnerItems returns up to count indices in [from, to) of the items
+	// owned by the address in any of its formats, in ascending order
+	GetOwnerItems(owner *address.Address, from uint64, to uint64, count uint64) ([]uint64, error)
 }
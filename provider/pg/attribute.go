@@ -0,0 +1,41 @@
+package pg
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/ton-community/compressed-nft-api/provider"
+	"github.com/ton-community/compressed-nft-api/types"
+)
+
+type AttributeProvider struct {
+	pool *pgxpool.Pool
+}
+
+func NewAttributeProvider(pool *pgxpool.Pool) *AttributeProvider {
+	return &AttributeProvider{
+		pool: pool,
+	}
+}
+
+var _ provider.AttributeProvider = (*AttributeProvider)(nil)
+
+func (ap *AttributeProvider) GetAttributes(index uint64) ([]types.Attribute, error) {
+	ctx := context.Background()
+	row := ap.pool.QueryRow(ctx, "SELECT attributes FROM items WHERE id = $1", index)
+	var b []byte
+	err := row.Scan(&b)
+	if err != nil {
+		return nil, err
+	}
+
+	if b == nil {
+		return nil, nil
+	}
+
+	var attrs []types.Attribute
+	err = json.Unmarshal(b, &attrs)
+
+	return attrs, err
+}
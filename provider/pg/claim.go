@@ -0,0 +1,82 @@
+package pg
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/ton-community/compressed-nft-api/provider"
+	"github.com/ton-community/compressed-nft-api/types"
+)
+
+type ClaimProvider struct {
+	pool *pgxpool.Pool
+}
+
+func NewClaimProvider(pool *pgxpool.Pool) *ClaimProvider {
+	return &ClaimProvider{
+		pool: pool,
+	}
+}
+
+var _ provider.ClaimProvider = (*ClaimProvider)(nil)
+
+func (cp *ClaimProvider) GetClaims(from, count uint64) (map[uint64]time.Time, error) {
+	ctx := context.Background()
+	rows, err := cp.pool.Query(ctx, "SELECT id, claimed_at FROM items WHERE id >= $1 AND id < $2 AND claimed", from, from+count)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	claims := map[uint64]time.Time{}
+	var index uint64
+	var at time.Time
+	for rows.Next() {
+		err = rows.Scan(&index, &at)
+		if err != nil {
+			return nil, err
+		}
+
+		claims[index] = at
+	}
+
+	return claims, rows.Err()
+}
+
+func (cp *ClaimProvider) SetClaimed(index uint64, at time.Time) error {
+	ctx := context.Background()
+	_, err := cp.pool.Exec(ctx, "UPDATE items SET claimed = true, claimed_at = $2 WHERE id = $1 AND NOT claimed", index, at)
+
+	return err
+}
+
+func (cp *ClaimProvider) GetStats() (*types.ClaimStats, error) {
+	ctx := context.Background()
+	row := cp.pool.QueryRow(ctx, "SELECT COUNT(*), MAX(claimed_at) FROM items WHERE claimed")
+
+	var stats types.ClaimStats
+	err := row.Scan(&stats.Claimed, &stats.LastClaimedAt)
+
+	return &stats, err
+}
+
+func (cp *ClaimProvider) GetCursor() (uint64, error) {
+	ctx := context.Background()
+	row := cp.pool.QueryRow(ctx, "SELECT lt FROM claim_cursor WHERE id = 1")
+	var lt uint64
+	err := row.Scan(&lt)
+	if err == pgx.ErrNoRows {
+		return 0, nil
+	}
+
+	return lt, err
+}
+
+func (cp *ClaimProvider) SetCursor(lt uint64) error {
+	ctx := context.Background()
+	_, err := cp.pool.Exec(ctx, "INSERT INTO claim_cursor (id, lt) VALUES (1, $1) ON CONFLICT (id) DO UPDATE SET lt = EXCLUDED.lt", lt)
+
+	return err
+}
@@ -0,0 +1,74 @@
+package pg
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/ton-community/compressed-nft-api/provider"
+	"github.com/ton-community/compressed-nft-api/types"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+type CollectionProvider struct {
+	pool *pgxpool.Pool
+}
+
+func NewCollectionProvider(pool *pgxpool.Pool) *CollectionProvider {
+	return &CollectionProvider{
+		pool: pool,
+	}
+}
+
+var _ provider.CollectionProvider = (*CollectionProvider)(nil)
+
+func (cp *CollectionProvider) GetCollection() (*types.Collection, error) {
+	ctx := context.Background()
+	row := cp.pool.QueryRow(ctx, "SELECT owner, collection_meta, common_item_meta, royalty_base, royalty_factor, royalty_recipient, api_link, depth, root, address, state_init, content_template FROM collection WHERE id = 1")
+
+	var owner, royaltyRecipient, addr string
+	var root, stateInit []byte
+	c := &types.Collection{}
+	err := row.Scan(&owner, &c.CollectionMeta, &c.CommonItemMeta, &c.RoyaltyBase, &c.RoyaltyFactor, &royaltyRecipient, &c.APILink, &c.Depth, &root, &addr, &stateInit, &c.ContentTemplate)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, provider.ErrCollectionNotExist
+		}
+		return nil, err
+	}
+
+	c.Owner, err = address.ParseAddr(owner)
+	if err != nil {
+		return nil, err
+	}
+
+	c.RoyaltyRecipient, err = address.ParseAddr(royaltyRecipient)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Address, err = address.ParseAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	c.StateInit, err = cell.FromBOC(stateInit)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Root = types.NewNode(root)
+
+	return c, nil
+}
+
+func (cp *CollectionProvider) SetCollection(c *types.Collection) error {
+	ctx := context.Background()
+	_, err := cp.pool.Exec(ctx, `INSERT INTO collection (id, owner, collection_meta, common_item_meta, royalty_base, royalty_factor, royalty_recipient, api_link, depth, root, address, state_init, content_template)
+VALUES (1, $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+ON CONFLICT (id) DO UPDATE SET owner = EXCLUDED.owner, collection_meta = EXCLUDED.collection_meta, common_item_meta = EXCLUDED.common_item_meta, royalty_base = EXCLUDED.royalty_base, royalty_factor = EXCLUDED.royalty_factor, royalty_recipient = EXCLUDED.royalty_recipient, api_link = EXCLUDED.api_link, depth = EXCLUDED.depth, root = EXCLUDED.root, address = EXCLUDED.address, state_init = EXCLUDED.state_init, content_template = EXCLUDED.content_template`,
+		c.Owner.String(), c.CollectionMeta, c.CommonItemMeta, c.RoyaltyBase, c.RoyaltyFactor, c.RoyaltyRecipient.String(), c.APILink, c.Depth, c.Root.Hash[:], c.Address.String(), c.StateInit.ToBOC(), c.ContentTemplate)
+
+	return err
+}
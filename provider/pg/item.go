@@ -2,7 +2,7 @@ package pg
 
 import (
 	"context"
-	"strconv"
+	"fmt"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	myaddr "github.com/ton-community/compressed-nft-api/address"
@@ -13,12 +13,14 @@ import (
 )
 
 type ItemProvider struct {
-	pool *pgxpool.Pool
+	pool            *pgxpool.Pool
+	contentTemplate string
 }
 
-func NewItemProvider(pool *pgxpool.Pool) *ItemProvider {
+func NewItemProvider(pool *pgxpool.Pool, contentTemplate string) *ItemProvider {
 	return &ItemProvider{
-		pool: pool,
+		pool:            pool,
+		contentTemplate: contentTemplate,
 	}
 }
 
@@ -33,18 +35,30 @@ func (ip *ItemProvider) Count() (uint64, error) {
 	return count, err
 }
 
-func makeMetadata(index uint64, owner *address.Address) *data.ItemMetadata {
+// makeMetadata uses the content stored with the item as a BOC, or the one
+// rendered from the content template if there is none
+func (ip *ItemProvider) makeMetadata(index uint64, owner *address.Address, content []byte) (*data.ItemMetadata, error) {
+	contentCell := data.ContentCell(ip.contentTemplate, index)
+	if content != nil {
+		var err error
+		contentCell, err = cell.FromBOC(content)
+		if err != nil {
+			return nil, fmt.Errorf("bad content of item %v: %w", index, err)
+		}
+	}
+
 	return &data.ItemMetadata{
 		Owner:             &myaddr.Address{Address: owner},
-		IndividualContent: cell.BeginCell().MustStoreStringSnake(strconv.FormatUint(index, 10) + ".json").EndCell(),
-	}
+		IndividualContent: contentCell,
+	}, nil
 }
 
 func (ip *ItemProvider) GetItem(index uint64) (*data.ItemMetadata, error) {
 	ctx := context.Background()
-	row := ip.pool.QueryRow(ctx, "SELECT owner FROM items WHERE id = $1", index)
+	row := ip.pool.QueryRow(ctx, "SELECT owner, content FROM items WHERE id = $1", index)
 	var addrString string
-	err := row.Scan(&addrString)
+	var content []byte
+	err := row.Scan(&addrString, &content)
 	if err != nil {
 		return nil, err
 	}
@@ -54,12 +68,12 @@ func (ip *ItemProvider) GetItem(index uint64) (*data.ItemMetadata, error) {
 		return nil, err
 	}
 
-	return makeMetadata(index, addr), nil
+	return ip.makeMetadata(index, addr, content)
 }
 
 func (ip *ItemProvider) GetItems(from, count uint64) ([]*data.ItemMetadata, error) {
 	ctx := context.Background()
-	rows, err := ip.pool.Query(ctx, "SELECT id, owner FROM items WHERE id >= $1 AND id < $2 ORDER BY id ASC", from, from+count)
+	rows, err := ip.pool.Query(ctx, "SELECT id, owner, content FROM items WHERE id >= $1 AND id < $2 ORDER BY id ASC", from, from+count)
 	if err != nil {
 		return nil, err
 	}
@@ -69,8 +83,9 @@ func (ip *ItemProvider) GetItems(from, count uint64) ([]*data.ItemMetadata, erro
 	datas := make([]*data.ItemMetadata, 0, count)
 	var index uint64
 	var addrString string
+	var content []byte
 	for rows.Next() {
-		err = rows.Scan(&index, &addrString)
+		err = rows.Scan(&index, &addrString, &content)
 		if err != nil {
 			return nil, err
 		}
@@ -85,7 +100,12 @@ func (ip *ItemProvider) GetItems(from, count uint64) ([]*data.ItemMetadata, erro
 		}
 		require = index + 1
 
-		datas = append(datas, makeMetadata(index, addr))
+		metadata, err := ip.makeMetadata(index, addr, content)
+		if err != nil {
+			return nil, err
+		}
+
+		datas = append(datas, metadata)
 	}
 
 	for i := uint64(0); i < count-uint64(len(datas)); i++ {
@@ -94,3 +114,25 @@ func (ip *ItemProvider) GetItems(from, count uint64) ([]*data.ItemMetadata, erro
 
 	return datas, nil
 }
+
+func (ip *ItemProvider) GetOwnerItems(owner *address.Address, from, to, count uint64) ([]uint64, error) {
+	ctx := context.Background()
+	rows, err := ip.pool.Query(ctx, "SELECT id FROM items WHERE owner = ANY($1::bpchar[]) AND id >= $2 AND id < $3 ORDER BY id ASC LIMIT $4", myaddr.Variants(owner), from, to, count)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	indices := make([]uint64, 0)
+	var index uint64
+	for rows.Next() {
+		err = rows.Scan(&index)
+		if err != nil {
+			return nil, err
+		}
+
+		indices = append(indices, index)
+	}
+
+	return indices, rows.Err()
+}
@@ -4,18 +4,33 @@ import (
 	"context"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/ton-community/compressed-nft-api/provider"
 	"github.com/ton-community/compressed-nft-api/types"
 )
 
+// querier is implemented by both a pool and a transaction
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
 type NodeProvider struct {
-	pool *pgxpool.Pool
+	db querier
 }
 
 func NewNodeProvider(pool *pgxpool.Pool) *NodeProvider {
 	return &NodeProvider{
-		pool: pool,
+		db: pool,
+	}
+}
+
+// NewTxNodeProvider returns a provider whose reads and writes happen in tx, so
+// that they can be committed or rolled back together
+func NewTxNodeProvider(tx pgx.Tx) *NodeProvider {
+	return &NodeProvider{
+		db: tx,
 	}
 }
 
@@ -23,7 +38,7 @@ var _ provider.NodeProvider = (*NodeProvider)(nil)
 
 func (np *NodeProvider) GetNode(index uint64, version int) (types.Node, error) {
 	ctx := context.Background()
-	row := np.pool.QueryRow(ctx, "SELECT hash FROM nodes WHERE index = $1 AND version <= $2 ORDER BY version DESC LIMIT 1", index, version)
+	row := np.db.QueryRow(ctx, "SELECT hash FROM nodes WHERE index = $1 AND version <= $2 ORDER BY version DESC LIMIT 1", index, version)
 	var hash []byte
 	err := row.Scan(&hash)
 	if err != nil {
@@ -38,7 +53,14 @@ func (np *NodeProvider) GetNode(index uint64, version int) (types.Node, error) {
 
 func (np *NodeProvider) SetNode(index uint64, version int, node types.Node) error {
 	ctx := context.Background()
-	_, err := np.pool.Exec(ctx, "INSERT INTO nodes (index, version, hash) VALUES ($1, $2, $3) ON CONFLICT (index, version) DO UPDATE SET hash = EXCLUDED.hash", index, version, node.Hash[:])
+	_, err := np.db.Exec(ctx, "INSERT INTO nodes (index, version, hash) VALUES ($1, $2, $3) ON CONFLICT (index, version) DO UPDATE SET hash = EXCLUDED.hash", index, version, node.Hash[:])
+
+	return err
+}
+
+func (np *NodeProvider) DeleteAll() error {
+	ctx := context.Background()
+	_, err := np.db.Exec(ctx, "DELETE FROM nodes")
 
 	return err
 }
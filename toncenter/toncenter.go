@@ -0,0 +1,182 @@
+package toncenter
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+const GET_METHOD_NAME = "get_merkle_root"
+
+var ErrBadResponse = errors.New("unexpected toncenter response")
+
+func GetMerkleRoot(uri string, addr *address.Address) ([]byte, error) {
+	return GetMerkleRootContext(context.Background(), http.DefaultClient, uri, addr)
+}
+
+// GetMerkleRootContext does the same as GetMerkleRoot with the given context
+// and HTTP client
+func GetMerkleRootContext(ctx context.Context, hc *http.Client, uri string, addr *address.Address) ([]byte, error) {
+	var r struct {
+		Address *address.Address `json:"address"`
+		Method  string           `json:"method"`
+		Stack   []any            `json:"stack"`
+	}
+
+	r.Address = addr
+	r.Method = GET_METHOD_NAME
+	r.Stack = []any{}
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uri+"runGetMethod", bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var m struct {
+		Ok     bool   `json:"ok"`
+		Error  string `json:"error"`
+		Result struct {
+			Stack    [][]any `json:"stack"`
+			ExitCode int     `json:"exit_code"`
+		} `json:"result"`
+	}
+
+	err = json.Unmarshal(b, &m)
+	if err != nil {
+		return nil, err
+	}
+
+	if !m.Ok {
+		return nil, fmt.Errorf("response is not successful: %v", m.Error)
+	}
+
+	if m.Result.ExitCode != 0 {
+		return nil, fmt.Errorf("%v exited with code %v", GET_METHOD_NAME, m.Result.ExitCode)
+	}
+
+	if len(m.Result.Stack) == 0 || len(m.Result.Stack[0]) < 2 {
+		return nil, ErrBadResponse
+	}
+
+	ns, ok := m.Result.Stack[0][1].(string)
+	if !ok {
+		return nil, ErrBadResponse
+	}
+
+	x := big.NewInt(0)
+
+	_, ok = x.SetString(ns, 0)
+	if !ok || x.Sign() < 0 || x.BitLen() > 256 {
+		return nil, ErrBadResponse
+	}
+
+	b = make([]byte, 32)
+	x.FillBytes(b)
+
+	return b, nil
+}
+
+type Message struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Value       string `json:"value"`
+	MsgData     struct {
+		Type string `json:"@type"`
+		Body string `json:"body"`
+	} `json:"msg_data"`
+}
+
+// Body returns nil for messages without a raw body
+func (m *Message) Body() (*cell.Cell, error) {
+	if m.MsgData.Type != "msg.dataRaw" || m.MsgData.Body == "" {
+		return nil, nil
+	}
+
+	b, err := base64.StdEncoding.DecodeString(m.MsgData.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return cell.FromBOC(b)
+}
+
+type TransactionID struct {
+	LT   string `json:"lt"`
+	Hash string `json:"hash"`
+}
+
+type Transaction struct {
+	Utime         int64         `json:"utime"`
+	TransactionID TransactionID `json:"transaction_id"`
+	InMsg         *Message      `json:"in_msg"`
+	OutMsgs       []*Message    `json:"out_msgs"`
+}
+
+func (tx *Transaction) LT() (uint64, error) {
+	return strconv.ParseUint(tx.TransactionID.LT, 10, 64)
+}
+
+// GetTransactions returns up to limit transactions of the account, newest
+// first, starting from the one with the given lt and hash (inclusive) or from
+// the latest one if lt is 0
+func GetTransactions(uri string, addr *address.Address, limit int, lt uint64, hash string) ([]*Transaction, error) {
+	q := url.Values{}
+	q.Set("address", addr.String())
+	q.Set("limit", strconv.Itoa(limit))
+	q.Set("archival", "true")
+	if lt != 0 {
+		q.Set("lt", strconv.FormatUint(lt, 10))
+		q.Set("hash", hash)
+	}
+
+	resp, err := http.Get(uri + "getTransactions?" + q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var m struct {
+		Ok     bool           `json:"ok"`
+		Error  string         `json:"error"`
+		Result []*Transaction `json:"result"`
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&m)
+	if err != nil {
+		return nil, err
+	}
+
+	if !m.Ok {
+		return nil, fmt.Errorf("response is not successful: %v", m.Error)
+	}
+
+	return m.Result, nil
+}
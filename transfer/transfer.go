@@ -0,0 +1,87 @@
+package transfer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+const TONKEEPER_PREFIX = "https://app.tonkeeper.com/transfer/"
+
+// how long a TonConnect request stays valid for wallets
+const TONCONNECT_TTL = 15 * time.Minute
+
+// Message is an internal message that the operator or a user has to send
+// from their wallet
+type Message struct {
+	Destination *address.Address
+	Amount      uint64
+	StateInit   *cell.Cell
+	Body        *cell.Cell
+}
+
+func (m *Message) query() string {
+	q := "amount=" + strconv.FormatUint(m.Amount, 10)
+	if m.Body != nil {
+		q += "&bin=" + base64.RawURLEncoding.EncodeToString(m.Body.ToBOC())
+	}
+	if m.StateInit != nil {
+		q += "&init=" + base64.RawURLEncoding.EncodeToString(m.StateInit.ToBOC())
+	}
+	return q
+}
+
+// Deeplink returns a ton://transfer link
+func (m *Message) Deeplink() string {
+	return fmt.Sprintf("ton://transfer/%v?%v", m.Destination.String(), m.query())
+}
+
+// TonkeeperLink returns a universal link that opens Tonkeeper on any device
+func (m *Message) TonkeeperLink() string {
+	return fmt.Sprintf("%v%v?%v", TONKEEPER_PREFIX, url.PathEscape(m.Destination.String()), m.query())
+}
+
+type TonConnectMessage struct {
+	Address   string `json:"address"`
+	Amount    string `json:"amount"`
+	Payload   string `json:"payload,omitempty"`
+	StateInit string `json:"stateInit,omitempty"`
+}
+
+// TonConnectRequest is the parameter of a TonConnect sendTransaction request
+type TonConnectRequest struct {
+	ValidUntil int64               `json:"validUntil"`
+	Messages   []TonConnectMessage `json:"messages"`
+}
+
+func (m *Message) TonConnect() TonConnectMessage {
+	tm := TonConnectMessage{
+		Address: m.Destination.String(),
+		Amount:  strconv.FormatUint(m.Amount, 10),
+	}
+	if m.Body != nil {
+		tm.Payload = base64.StdEncoding.EncodeToString(m.Body.ToBOC())
+	}
+	if m.StateInit != nil {
+		tm.StateInit = base64.StdEncoding.EncodeToString(m.StateInit.ToBOC())
+	}
+	return tm
+}
+
+// TonConnect builds a sendTransaction request that sends all the messages in
+// one transaction
+func TonConnect(msgs ...*Message) *TonConnectRequest {
+	r := &TonConnectRequest{
+		ValidUntil: time.Now().Add(TONCONNECT_TTL).Unix(),
+		Messages:   make([]TonConnectMessage, 0, len(msgs)),
+	}
+	for _, m := range msgs {
+		r.Messages = append(r.Messages, m.TonConnect())
+	}
+	return r
+}
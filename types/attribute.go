@@ -0,0 +1,8 @@
+package types
+
+// Attribute is a TEP-64 item attribute; Value is usually a string or a
+// number
+type Attribute struct {
+	TraitType string `json:"trait_type" yaml:"trait_type"`
+	Value     any    `json:"value" yaml:"value"`
+}
@@ -0,0 +1,8 @@
+package types
+
+import "time"
+
+type ClaimStats struct {
+	Claimed       uint64
+	LastClaimedAt *time.Time
+}
@@ -0,0 +1,25 @@
+package types
+
+import (
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+// Collection holds the params a collection was deployed with, its computed
+// address and state init
+type Collection struct {
+	Owner            *address.Address
+	CollectionMeta   string
+	CommonItemMeta   string
+	RoyaltyBase      uint64
+	RoyaltyFactor    uint64
+	RoyaltyRecipient *address.Address
+	APILink          string
+	Depth            int
+	Root             Node
+	Address          *address.Address
+	StateInit        *cell.Cell
+	// ContentTemplate is the template the individual content of items is
+	// rendered from, see data.RenderContent
+	ContentTemplate string
+}
@@ -5,7 +5,17 @@ import (
 	"encoding/json"
 )
 
-const MAX_LEVELS = 30
+// CONTRACT_MAX_DEPTH is the deepest tree the collection contract supports: it
+// stores item indices as 64-bit integers in the item data, so it can deploy at
+// most 2^64 items
+const CONTRACT_MAX_DEPTH = 64
+
+// STORAGE_MAX_DEPTH is the deepest tree the nodes table can hold: node indices
+// go up to 2^(depth+1)-1 and are stored as signed 64-bit integers
+const STORAGE_MAX_DEPTH = 62
+
+// MAX_DEPTH is the smaller of the two limits, which is the storage one
+const MAX_DEPTH = STORAGE_MAX_DEPTH
 const NODE_LENGTH = 32
 
 type Node struct {
@@ -5,6 +5,7 @@ import "github.com/ton-community/compressed-nft-api/address"
 type State struct {
 	LastIndex uint64
 	Version   int
+	Depth     int
 	Root      Node
 	Address   *address.Address
 }
@@ -11,13 +11,17 @@ type FileUpdateRecorder struct {
 	Base string
 }
 
+func (up *FileUpdateRecorder) path(version int) string {
+	return path.Join(up.Base, strconv.FormatInt(int64(version), 10)+".json")
+}
+
 func (up *FileUpdateRecorder) Record(upd any, toVersion int) error {
 	err := os.MkdirAll(up.Base, os.ModePerm)
 	if err != nil {
 		return err
 	}
 
-	f, err := os.Create(path.Join(up.Base, strconv.FormatInt(int64(toVersion), 10)+".json"))
+	f, err := os.Create(up.path(toVersion))
 	if err != nil {
 		return err
 	}
@@ -27,3 +31,13 @@ func (up *FileUpdateRecorder) Record(upd any, toVersion int) error {
 
 	return err
 }
+
+func (up *FileUpdateRecorder) Load(version int, upd any) error {
+	f, err := os.Open(up.path(version))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return json.NewDecoder(f).Decode(upd)
+}
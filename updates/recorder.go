@@ -2,4 +2,5 @@ package updates
 
 type Recorder interface {
 	Record(upd any, toVersion int) error
+	Load(version int, upd any) error
 }
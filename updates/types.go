@@ -21,3 +21,18 @@ type Create struct {
 	Depth     int    `json:"depth"`
 	LastIndex uint64 `json:"last_index"`
 }
+
+type Record struct {
+	Type         string     `json:"type"`
+	Root         types.Node `json:"root"`
+	LastIndex    uint64     `json:"last_index"`
+	NewLastIndex uint64     `json:"new_last_index"`
+}
+
+func (r *Record) GetLastIndex() uint64 {
+	if r.Type == "create" {
+		return r.LastIndex
+	}
+
+	return r.NewLastIndex
+}
@@ -2,11 +2,6 @@ package updates
 
 import (
 	"bytes"
-	"encoding/json"
-	"errors"
-	"io"
-	"math/big"
-	"net/http"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -14,12 +9,11 @@ import (
 	"github.com/ton-community/compressed-nft-api/config"
 	"github.com/ton-community/compressed-nft-api/provider"
 	"github.com/ton-community/compressed-nft-api/state"
+	"github.com/ton-community/compressed-nft-api/toncenter"
 	"github.com/ton-community/compressed-nft-api/types"
 	"github.com/xssnick/tonutils-go/address"
 )
 
-const GET_METHOD_NAME = "get_merkle_root"
-
 func Watcher(newStates <-chan *types.State, addrs <-chan *address.Address, sh *state.StateHolder, sp provider.StateProvider) {
 	var addr *address.Address
 	var newState *types.State
@@ -40,7 +34,7 @@ func Watcher(newStates <-chan *types.State, addrs <-chan *address.Address, sh *s
 				continue
 			}
 
-			rootb, err := getMerkleRoot(addr)
+			rootb, err := toncenter.GetMerkleRoot(config.Config.Toncenter, addr)
 			if err != nil {
 				log.Err(err).Msg("could not get merkle root")
 				continue
@@ -68,53 +62,3 @@ func Watcher(newStates <-chan *types.State, addrs <-chan *address.Address, sh *s
 		}
 	}
 }
-
-func getMerkleRoot(addr *address.Address) ([]byte, error) {
-	var r struct {
-		Address *address.Address `json:"address"`
-		Method  string           `json:"method"`
-		Stack   []any            `json:"stack"`
-	}
-
-	r.Address = addr
-	r.Method = GET_METHOD_NAME
-	r.Stack = []any{}
-
-	b, err := json.Marshal(r)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := http.Post(config.Config.Toncenter+"runGetMethod", "application/json", bytes.NewReader(b))
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	b, err = io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var m map[string]any
-
-	err = json.Unmarshal(b, &m)
-	if err != nil {
-		return nil, err
-	}
-
-	if !m["ok"].(bool) {
-		return nil, errors.New("response is not successful")
-	}
-
-	ns := (((m["result"].(map[string]any))["stack"].([]any))[0].([]any))[1].(string)
-
-	x := big.NewInt(0)
-
-	x.SetString(ns, 0)
-
-	b = make([]byte, 32)
-	x.FillBytes(b)
-
-	return b, nil
-}